@@ -19,11 +19,12 @@ var (
 	}
 )
 
-func runDelete(_ *cobra.Command, _ []string) {
+func runDelete(cmd *cobra.Command, _ []string) {
+	ctx := cmd.Context()
 	logger := flag.Global.Logger()
 	client := aws.NewClient(logger, flag.Global.AWSConfig())
 
-	flowLogs := prompt.ListFlowLogs(client, aws.FlowLogTypeAll)
+	flowLogs := prompt.ListFlowLogs(ctx, client, aws.FlowLogTypeAll)
 	if len(flowLogs) == 0 {
 		fmt.Println("no flow logs found, nothing to delete")
 		return
@@ -36,7 +37,7 @@ func runDelete(_ *cobra.Command, _ []string) {
 
 	prompt.Confirm("selected flow logs, continue")
 
-	if err := client.DeleteResources(flowLogs); err != nil {
+	if err := client.DeleteResources(ctx, flowLogs); err != nil {
 		fmt.Printf("delete flow logs: %v\n", err)
 		os.Exit(1)
 	}
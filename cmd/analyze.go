@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pete911/flowlogs/cmd/flag"
+	"github.com/pete911/flowlogs/cmd/out"
+	"github.com/pete911/flowlogs/internal/aws"
+	"github.com/pete911/flowlogs/internal/aws/ec2"
+	"github.com/pete911/flowlogs/internal/export"
+	"github.com/spf13/cobra"
+)
+
+var Analyze = &cobra.Command{
+	Use:     "analyze <files|dirs>",
+	Aliases: []string{"analyse"},
+	Short:   "analyze flow log files (S3 exports, gzip archives, pasted records or - for stdin) without AWS access",
+	Long:    "",
+	Args:    cobra.MinimumNArgs(1),
+	Run:     runAnalyze,
+}
+
+func init() {
+	flag.InitAnalyzeFlags(Analyze, &flag.Analyze, &flag.Query)
+	Root.AddCommand(Analyze)
+}
+
+func runAnalyze(cmd *cobra.Command, args []string) {
+	if flag.Query.Follow {
+		fmt.Println("query flags: follow cannot be used with flow log files")
+		os.Exit(1)
+	}
+	q, err := flag.Query.GetQuery()
+	if err != nil {
+		fmt.Printf("query flags: %v\n", err)
+		os.Exit(1)
+	}
+	// records are filtered or merged to flows after evaluation, limit flag applies to the client-side results
+	if flag.Query.IsClientSide() {
+		q = q.Complete()
+	}
+	format := flag.Query.OutputFormat()
+	optional := flag.Query.Columns()
+	logger := flag.Global.Logger()
+	ranges := loadIPRanges(optional)
+
+	result, err := export.NewReader(flag.Analyze.LogFormat).Read(args...)
+	if err != nil {
+		fmt.Printf("read flow logs: %v\n", err)
+		os.Exit(1)
+	}
+	records := result.Records
+	// default time range (minutes flag) is relative to now, files are usually older, so only explicit range is applied
+	if flag.Query.HasTimeRange() {
+		records = inTimeRange(records, q.GetStart(), q.GetEnd())
+	}
+
+	results, matched, err := q.Evaluate(records)
+	if err != nil {
+		fmt.Printf("analyze flow logs: %v\n", err)
+		os.Exit(1)
+	}
+	stats := queryStatistics{
+		RecordsScanned: float64(len(result.Records)),
+		BytesScanned:   float64(result.Bytes),
+		RecordsMatched: float64(matched),
+	}
+
+	if aggregation, ok := q.GetAggregation(); ok {
+		printAggregation(logger, format, results, aggregation, stats)
+		printAnalyzeStatistics(format, stats, result.Files)
+		return
+	}
+
+	if flag.Query.Conversations {
+		printConversations(logger, format, results, stats)
+		printAnalyzeStatistics(format, stats, result.Files)
+		return
+	}
+
+	columns := queryColumns(ranges, optional)
+	if flag.Query.Pretty {
+		// names are optional for files, flow logs can be analyzed without access to the account
+		client := aws.NewClient(logger, flag.Global.AWSConfig())
+		interfaces, err := client.ListNetworkInterfaces(cmd.Context())
+		if err != nil {
+			logger.Warn(fmt.Sprintf("pretty: list network interfaces: %v", err))
+			interfaces = ec2.NetworkInterfaces{}
+		}
+		columns = prettyQueryColumns(interfaces, ranges, optional)
+	}
+	if flag.Query.MergeFlows {
+		columns = mergedFlowColumns(columns)
+	}
+	if flag.Query.IsClientSide() {
+		results = clientSideResults(ranges, results)
+	}
+	p := newPrinter(logger, format, rowColumns(format, columns))
+	p.addRows(results)
+	p.setStatistics(stats)
+	p.print()
+	printAnalyzeStatistics(format, stats, result.Files)
+}
+
+// inTimeRange returns records with flow log start time in the time range
+func inTimeRange(records []map[string]string, start, end time.Time) []map[string]string {
+	var out []map[string]string
+	for _, record := range records {
+		sec, err := strconv.ParseInt(record["start"], 10, 64)
+		if err != nil {
+			continue
+		}
+		if t := time.Unix(sec, 0); !t.Before(start) && t.Before(end) {
+			out = append(out, record)
+		}
+	}
+	return out
+}
+
+// printAnalyzeStatistics prints read and matched records to stderr, json output already contains them
+func printAnalyzeStatistics(format out.Format, stats queryStatistics, files int) {
+	if format == out.FormatJSON {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "read %s records (%s) from %d files, matched %s records\n",
+		formatCount(stats.RecordsScanned), formatBytes(stats.BytesScanned), files, formatCount(stats.RecordsMatched))
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/pete911/flowlogs/cmd/out"
+	"github.com/pete911/flowlogs/internal/aws/query"
+)
+
+// printConversations joins flow logs to end-to-end conversations and prints one row per conversation, hops column
+// lists network interfaces with bytes sent by client and server (out/in) recorded by the interface
+func printConversations(logger *slog.Logger, format out.Format, logs []map[string]string, stats queryStatistics) {
+	var rows []map[string]string
+	for _, c := range query.Conversations(logs) {
+		var hops []string
+		for _, h := range c.Hops {
+			name := h.InterfaceId
+			if h.NAT {
+				name = fmt.Sprintf("%s (nat)", name)
+			}
+			hops = append(hops, fmt.Sprintf("%s %d/%d", name, h.BytesOut, h.BytesIn))
+		}
+		rows = append(rows, map[string]string{
+			"client":   c.Client.String(),
+			"server":   c.Server.String(),
+			"protocol": query.ProtocolFromNumberToKeyword(c.Protocol),
+			"action":   conversationAction(c),
+			"bytesOut": strconv.FormatInt(c.BytesOut(), 10),
+			"bytesIn":  strconv.FormatInt(c.BytesIn(), 10),
+			"hops":     strings.Join(hops, ", "),
+		})
+	}
+
+	columns := []column{
+		{name: "CLIENT", key: "client"},
+		{name: "SERVER", key: "server"},
+		{name: "PROTOCOL", key: "protocol"},
+		{name: "ACTION", key: "action"},
+		{name: "BYTES OUT", key: "bytesOut"},
+		{name: "BYTES IN", key: "bytesIn"},
+		{name: "HOPS (BYTES OUT/IN)", key: "hops"},
+	}
+	for i := range columns {
+		key := columns[i].key
+		columns[i].value = func(row map[string]string) string { return row[key] }
+	}
+
+	p := newPrinter(logger, format, columns)
+	p.addRows(rows)
+	p.setStatistics(stats)
+	p.print()
+}
+
+// conversationAction returns ACCEPT or REJECT, or both if the conversation was accepted by some hops and rejected by
+// other hops (e.g. security group allows egress, but network ACL rejects return traffic)
+func conversationAction(c query.Conversation) string {
+	switch {
+	case c.Accepted > 0 && c.Rejected > 0:
+		return "ACCEPT, REJECT"
+	case c.Rejected > 0:
+		return "REJECT"
+	case c.Accepted > 0:
+		return "ACCEPT"
+	}
+	return ""
+}
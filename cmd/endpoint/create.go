@@ -20,12 +20,13 @@ var (
 	}
 )
 
-func runCreate(_ *cobra.Command, _ []string) {
+func runCreate(cmd *cobra.Command, _ []string) {
+	ctx := cmd.Context()
 	logger := flag.Global.Logger()
 	client := aws.NewClient(logger, flag.Global.AWSConfig())
 
-	selectedEndpoint := prompt.SelectVPCEndpoint(prompt.ListVPCEndpoints(client), false)
-	logGroup, err := client.CreateVPCEndpointFlowLogs(selectedEndpoint)
+	selectedEndpoint := prompt.SelectVPCEndpoint(prompt.ListVPCEndpoints(ctx, client), false)
+	logGroup, err := client.CreateVPCEndpointFlowLogs(ctx, selectedEndpoint)
 	if err != nil {
 		fmt.Printf("create flow logs: %v\n", err)
 		os.Exit(1)
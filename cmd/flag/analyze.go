@@ -0,0 +1,29 @@
+package flag
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var Analyze AnalyzeFlags
+
+// AnalyzeFlags are flags of the analyze command, query flags are shared with the query command
+type AnalyzeFlags struct {
+	LogFormat string
+}
+
+// cloudWatchQueryFlags are query flags that apply only to flow logs queried in CloudWatch
+var cloudWatchQueryFlags = []string{"follow", "timeout", "price-per-gb", "max-scan-gb", "insights", "log-group"}
+
+// InitAnalyzeFlags sets query flags (without CloudWatch flags) and analyze flags
+func InitAnalyzeFlags(cmd *cobra.Command, flags *AnalyzeFlags, queryFlags *QueryFlags) {
+	InitPersistentQueryFlags(cmd, queryFlags)
+	for _, name := range cloudWatchQueryFlags {
+		_ = cmd.PersistentFlags().MarkHidden(name)
+	}
+	cmd.PersistentFlags().StringVar(
+		&flags.LogFormat,
+		"log-format",
+		getStringEnv("LOG_FORMAT", ""),
+		"log format of records without header line ('${version} ${srcaddr} ...'), detected by number of values if not set",
+	)
+}
@@ -0,0 +1,74 @@
+package flag
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pete911/flowlogs/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const presetFlag = "preset"
+
+// ApplyConfig sets flag values from the config file - query preset (preset flag) and defaults for the current AWS
+// profile. Flags set on the command line or by environment variables take precedence over preset and preset takes
+// precedence over defaults.
+func ApplyConfig(cmd *cobra.Command) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	flags := cmd.Flags()
+	values := cfg.GetDefaults(config.AWSProfile()).Flags()
+	if f := flags.Lookup(presetFlag); f != nil && f.Value.String() != "" {
+		preset, err := cfg.GetPreset(f.Value.String())
+		if err != nil {
+			return err
+		}
+		for name, value := range preset {
+			if flags.Lookup(name) == nil {
+				return fmt.Errorf("preset %s: unknown flag %s", f.Value.String(), name)
+			}
+			values[name] = value
+		}
+	}
+
+	for name, value := range values {
+		f := flags.Lookup(name)
+		// defaults can contain flags that are not used by the command
+		if f == nil || f.Changed || isEnvSet(name) {
+			continue
+		}
+		// set value directly, so the flag is not marked as changed (set on command line)
+		if err := f.Value.Set(value); err != nil {
+			return fmt.Errorf("config flag %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// HasQueryFlags returns true if the command has query flags (query, analyze and preset save commands)
+func HasQueryFlags(cmd *cobra.Command) bool {
+	return cmd.Flags().Lookup(presetFlag) != nil
+}
+
+// PresetFromFlags returns preset from command flags set on the command line
+func PresetFromFlags(cmd *cobra.Command) config.Preset {
+	preset := make(config.Preset)
+	cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		if !f.Changed || f.Name == presetFlag {
+			return
+		}
+		preset[f.Name] = f.Value.String()
+	})
+	return preset
+}
+
+// isEnvSet returns true if the environment variable for the flag (AWSFL_<FLAG_NAME>) is set
+func isEnvSet(flagName string) bool {
+	_, ok := os.LookupEnv(fmt.Sprintf("AWSFL_%s", strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))))
+	return ok
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pete911/flowlogs/internal/aws"
 	"github.com/spf13/cobra"
@@ -75,6 +76,28 @@ func getBoolEnv(envName string, defaultValue bool) bool {
 	return defaultValue
 }
 
+func getDurationEnv(envName string, defaultValue time.Duration) time.Duration {
+	env, ok := os.LookupEnv(fmt.Sprintf("AWSFL_%s", envName))
+	if !ok {
+		return defaultValue
+	}
+	if out, err := time.ParseDuration(env); err == nil {
+		return out
+	}
+	return defaultValue
+}
+
+func getFloat64Env(envName string, defaultValue float64) float64 {
+	env, ok := os.LookupEnv(fmt.Sprintf("AWSFL_%s", envName))
+	if !ok {
+		return defaultValue
+	}
+	if out, err := strconv.ParseFloat(env, 64); err == nil {
+		return out
+	}
+	return defaultValue
+}
+
 func getIntEnv(envName string, defaultValue int) int {
 	env, ok := os.LookupEnv(fmt.Sprintf("AWSFL_%s", envName))
 	if !ok {
@@ -85,3 +108,14 @@ func getIntEnv(envName string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// splitList splits comma separated list and removes empty values
+func splitList(in string) []string {
+	var out []string
+	for _, v := range strings.Split(in, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,112 @@
+package flag
+
+import (
+	"fmt"
+
+	"github.com/pete911/flowlogs/internal/aws/query"
+	"github.com/pete911/flowlogs/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var Noise NoiseFlags
+
+// NoiseFlags are exclusion flags, used by query command and to save noise profiles
+type NoiseFlags struct {
+	notAddr     string
+	notPort     string
+	notNiId     string
+	notProtocol string
+}
+
+// GetNoiseProfile returns validated noise profile from the exclusion flags
+func (f NoiseFlags) GetNoiseProfile() (config.NoiseProfile, error) {
+	profile := config.NoiseProfile{
+		Addresses:    splitList(f.notAddr),
+		Ports:        splitList(f.notPort),
+		InterfaceIds: splitList(f.notNiId),
+		Protocols:    splitList(f.notProtocol),
+	}
+	if err := validateNoiseProfile(profile); err != nil {
+		return config.NoiseProfile{}, err
+	}
+	return profile, nil
+}
+
+func validateNoiseProfile(profile config.NoiseProfile) error {
+	for _, addr := range profile.Addresses {
+		if err := query.ValidateAddress(addr); err != nil {
+			return fmt.Errorf("not-addr: %w", err)
+		}
+	}
+	for _, port := range profile.Ports {
+		if _, err := query.ParsePorts(port); err != nil {
+			return fmt.Errorf("not-port: %w", err)
+		}
+	}
+	for _, protocol := range profile.Protocols {
+		if _, err := query.ParseProtocol(protocol); err != nil {
+			return fmt.Errorf("not-protocol: %w", err)
+		}
+	}
+	return nil
+}
+
+// excludeNoise adds noise profile exclusions to the query
+func excludeNoise(q query.Query, profile config.NoiseProfile) (query.Query, error) {
+	if err := validateNoiseProfile(profile); err != nil {
+		return query.Query{}, err
+	}
+
+	for _, addr := range profile.Addresses {
+		q = q.NotAddress(addr)
+	}
+	for _, id := range profile.InterfaceIds {
+		q = q.NotInterfaceId(id)
+	}
+	if len(profile.Protocols) > 0 {
+		q = q.NotProtocols(profile.Protocols...)
+	}
+	var ports query.Ports
+	for _, port := range profile.Ports {
+		// already validated
+		p, _ := query.ParsePorts(port)
+		ports = append(ports, p...)
+	}
+	if len(ports) > 0 {
+		q = q.NotPorts(ports)
+	}
+	return q, nil
+}
+
+func initNoiseFlags(flagSet *pflag.FlagSet, flags *NoiseFlags) {
+	flagSet.StringVar(
+		&flags.notAddr,
+		"not-addr",
+		getStringEnv("NOT_ADDR", ""),
+		"exclude address - source, destination or packet, comma separated IPs or CIDRs",
+	)
+	flagSet.StringVar(
+		&flags.notPort,
+		"not-port",
+		getStringEnv("NOT_PORT", ""),
+		"exclude port - source or destination, comma separated ports and ranges (123,1024-65535)",
+	)
+	flagSet.StringVar(
+		&flags.notNiId,
+		"not-ni-id",
+		getStringEnv("NOT_NI_ID", ""),
+		"exclude network interface id, comma separated ids",
+	)
+	flagSet.StringVar(
+		&flags.notProtocol,
+		"not-protocol",
+		getStringEnv("NOT_PROTOCOL", ""),
+		"exclude protocol, comma separated keywords or numbers (udp,icmp)",
+	)
+}
+
+// InitNoiseFlags adds exclusion flags to the command (e.g. noise profile save command)
+func InitNoiseFlags(cmd *cobra.Command, flags *NoiseFlags) {
+	initNoiseFlags(cmd.Flags(), flags)
+}
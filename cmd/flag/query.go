@@ -1,40 +1,185 @@
 package flag
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pete911/flowlogs/cmd/out"
 	"github.com/pete911/flowlogs/internal/aws/query"
+	"github.com/pete911/flowlogs/internal/config"
+	"github.com/pete911/flowlogs/internal/ipranges"
 	"github.com/spf13/cobra"
 )
 
 var Query QueryFlags
 
 type QueryFlags struct {
-	Pretty       bool
-	limit        int
-	sinceMinutes int
-	niId         string
-	protocol     string
-	ingress      bool
-	egress       bool
-	accept       bool
-	reject       bool
-	port         int
-	addr         string
-	srcPort      int
-	srcAddr      string
-	pktSrcAddr   string
-	dstPort      int
-	dstAddr      string
-	pktDstAddr   string
-}
-
-func (f QueryFlags) GetQuery() query.Query {
-	q := query.NewQuery(f.limit, f.sinceMinutes)
+	Pretty        bool
+	Conversations bool
+	MergeFlows    bool
+	Follow        bool
+	Timeout       time.Duration
+	PricePerGB    float64
+	MaxScanGB     float64
+	output        string
+	columns       string
+	preset        string
+	insights      string
+	logGroups     string
+	limit         int
+	all           bool
+	top           string
+	sum           string
+	count         bool
+	histogram     string
+	sinceMinutes  int
+	start         string
+	end           string
+	niId          string
+	subnetId      string
+	instanceId    string
+	trafficType   string
+	awsService    string
+	remoteService string
+	remoteRegion  string
+	ipRanges      string
+	protocol      string
+	tcpFlags      string
+	synOnly       bool
+	rst           bool
+	fin           bool
+	ingress       bool
+	egress        bool
+	accept        bool
+	reject        bool
+	port          string
+	addr          string
+	srcPort       string
+	srcAddr       string
+	pktSrcAddr    string
+	dstPort       string
+	dstAddr       string
+	pktDstAddr    string
+	where         string
+	noise         NoiseFlags
+	noiseNames    string
+}
+
+func (f QueryFlags) OutputFormat() out.Format {
+	format, err := out.ParseFormat(f.output)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	// json is printed once all the results are received, which never happens when following flow logs
+	if f.Follow && format == out.FormatJSON {
+		fmt.Println("json output cannot be used with follow, use ndjson instead")
+		os.Exit(1)
+	}
+	return format
+}
+
+// TimeoutContext returns context that is cancelled after the timeout flag, zero timeout disables it
+func (f QueryFlags) TimeoutContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if f.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, f.Timeout)
+}
+
+// HasTimeRange returns true if start or end flag is set
+func (f QueryFlags) HasTimeRange() bool {
+	return f.start != "" || f.end != ""
+}
+
+// LogGroups returns log group names from log group flag, log groups are queried instead of selecting flow logs
+func (f QueryFlags) LogGroups() []string {
+	return splitList(f.logGroups)
+}
+
+// OptionalColumns are keys of columns that can be added to the table output with columns flag
+var OptionalColumns = []string{"log-group", "vpc-id", "subnet-id", "instance-id", "type", "aws-service", "remote-service", "remote-region", "start", "end"}
+
+// Columns returns optional columns requested by columns flag, program exits if any of the columns is not supported
+func (f QueryFlags) Columns() []string {
+	columns := splitList(f.columns)
+	for _, c := range columns {
+		if !slices.Contains(OptionalColumns, c) {
+			fmt.Printf("invalid column %q, supported columns: %s\n", c, strings.Join(OptionalColumns, ", "))
+			os.Exit(1)
+		}
+	}
+	return columns
+}
+
+func (f QueryFlags) GetQuery() (query.Query, error) {
+	if err := f.validateAddresses(); err != nil {
+		return query.Query{}, err
+	}
+
+	if f.MergeFlows {
+		if err := f.validateMergeFlows(); err != nil {
+			return query.Query{}, err
+		}
+	}
+	if !f.RemoteFilter().IsEmpty() {
+		if err := f.validateRemoteFilter(); err != nil {
+			return query.Query{}, err
+		}
+	}
+	// flows are filtered or merged from records, limit is applied to the client-side results (see ResultLimit)
+	limit := f.limit
+	if f.IsClientSide() {
+		limit = query.MaxLimit
+	}
+	q := query.NewQuery(limit, f.sinceMinutes)
+	if f.start != "" || f.end != "" {
+		start, end, err := f.timeRange(time.Now())
+		if err != nil {
+			return query.Query{}, err
+		}
+		q = q.TimeRange(start, end)
+	}
+	if f.all {
+		q = q.Complete()
+	}
+	noiseProfile, err := f.noiseProfile()
+	if err != nil {
+		return query.Query{}, err
+	}
 	q = q.NoNoData().NoSkipData()
 	if f.niId != "" {
 		q = q.InterfaceId(f.niId)
 	}
-	if f.protocol != "" {
-		q = q.Protocol(f.protocol)
+	if f.subnetId != "" {
+		q = q.SubnetId(f.subnetId)
+	}
+	if f.instanceId != "" {
+		q = q.InstanceId(f.instanceId)
+	}
+	if f.trafficType != "" {
+		trafficType, err := query.ParseTrafficType(f.trafficType)
+		if err != nil {
+			return query.Query{}, fmt.Errorf("type: %w", err)
+		}
+		q = q.TrafficType(trafficType)
+	}
+	if f.awsService != "" {
+		q = q.AwsService(f.awsService)
+	}
+	if protocols := splitList(f.protocol); len(protocols) > 0 {
+		for _, protocol := range protocols {
+			if _, err := query.ParseProtocol(protocol); err != nil {
+				return query.Query{}, fmt.Errorf("protocol: %w", err)
+			}
+		}
+		q = q.Protocols(protocols...)
 	}
 	if f.egress {
 		q = q.Egress()
@@ -48,14 +193,25 @@ func (f QueryFlags) GetQuery() query.Query {
 	if f.reject {
 		q = q.Reject()
 	}
-	if f.port > -1 {
-		q = q.Port(f.port)
+	tcpFlags, err := f.tcpFlagsFilters()
+	if err != nil {
+		return query.Query{}, err
+	}
+	for _, flags := range tcpFlags {
+		q = q.TcpFlags(flags)
+	}
+	if ports, err := parsePorts(f.port); err != nil {
+		return query.Query{}, fmt.Errorf("port: %w", err)
+	} else if len(ports) > 0 {
+		q = q.Ports(ports)
 	}
 	if f.addr != "" {
 		q = q.Address(f.addr)
 	}
-	if f.srcPort > -1 {
-		q = q.SourcePort(f.srcPort)
+	if ports, err := parsePorts(f.srcPort); err != nil {
+		return query.Query{}, fmt.Errorf("src-port: %w", err)
+	} else if len(ports) > 0 {
+		q = q.SourcePorts(ports)
 	}
 	if f.srcAddr != "" {
 		q = q.SourceAddress(f.srcAddr)
@@ -63,8 +219,10 @@ func (f QueryFlags) GetQuery() query.Query {
 	if f.pktSrcAddr != "" {
 		q = q.PktSourceAddress(f.pktSrcAddr)
 	}
-	if f.dstPort > -1 {
-		q = q.DestinationPort(f.dstPort)
+	if ports, err := parsePorts(f.dstPort); err != nil {
+		return query.Query{}, fmt.Errorf("dst-port: %w", err)
+	} else if len(ports) > 0 {
+		q = q.DestinationPorts(ports)
 	}
 	if f.dstAddr != "" {
 		q = q.DestinationAddress(f.dstAddr)
@@ -72,7 +230,269 @@ func (f QueryFlags) GetQuery() query.Query {
 	if f.pktDstAddr != "" {
 		q = q.PktDestinationAddress(f.pktDstAddr)
 	}
-	return q.Sort()
+	if f.where != "" {
+		e, err := query.ParseWhere(f.where)
+		if err != nil {
+			return query.Query{}, fmt.Errorf("where: %w", err)
+		}
+		q = q.Where(e)
+	}
+	if q, err = excludeNoise(q, noiseProfile); err != nil {
+		return query.Query{}, err
+	}
+	if err := q.Err(); err != nil {
+		return query.Query{}, err
+	}
+	if f.insights != "" {
+		// live tail filters flow logs client-side, raw pipeline cannot be applied
+		if f.Follow {
+			return query.Query{}, errors.New("follow cannot be used with insights")
+		}
+		q = q.Insights(f.insights)
+		if f.all && !q.IsMergeable() {
+			return query.Query{}, errors.New("all cannot be used with insights pipeline that has stats, sort or dedup, results of split time range cannot be merged")
+		}
+	}
+
+	if f.Conversations {
+		if err := f.validateConversations(); err != nil {
+			return query.Query{}, err
+		}
+	}
+	if f.top != "" || f.sum != "" || f.count || f.histogram != "" {
+		aggregation, err := f.aggregation(q.GetEnd().Sub(q.GetStart()))
+		if err != nil {
+			return query.Query{}, err
+		}
+		return q.Stats(aggregation), nil
+	}
+	// raw pipeline can sort or aggregate results, @timestamp field might not be present
+	if q.HasStage("sort") || q.HasStage("stats") {
+		return q, nil
+	}
+	return q.Sort(), nil
+}
+
+// validateConversations returns error if conversations are combined with flags that do not return flow log records
+func (f QueryFlags) validateConversations() error {
+	switch {
+	case f.Follow:
+		return errors.New("follow cannot be used with conversations")
+	case f.insights != "":
+		return errors.New("insights cannot be used with conversations")
+	case f.top != "" || f.sum != "" || f.count || f.histogram != "":
+		return errors.New("conversations cannot be used with aggregation (top, sum, count, histogram)")
+	}
+	return nil
+}
+
+// validateMergeFlows returns error if merged flows are combined with flags that do not return flow log records
+func (f QueryFlags) validateMergeFlows() error {
+	switch {
+	case f.Follow:
+		return errors.New("follow cannot be used with merge-flows")
+	case f.insights != "":
+		return errors.New("insights cannot be used with merge-flows")
+	case f.Conversations:
+		return errors.New("conversations cannot be used with merge-flows")
+	case f.top != "" || f.sum != "" || f.count || f.histogram != "":
+		return errors.New("merge-flows cannot be used with aggregation (top, sum, count, histogram)")
+	}
+	return nil
+}
+
+// validateRemoteFilter returns error if remote service and region filters are combined with flags that do not return
+// flow log records
+func (f QueryFlags) validateRemoteFilter() error {
+	switch {
+	case f.Follow:
+		return errors.New("follow cannot be used with remote-service and remote-region")
+	case f.insights != "":
+		return errors.New("insights cannot be used with remote-service and remote-region")
+	case f.Conversations:
+		return errors.New("conversations cannot be used with remote-service and remote-region")
+	case f.top != "" || f.sum != "" || f.count || f.histogram != "":
+		return errors.New("remote-service and remote-region cannot be used with aggregation (top, sum, count, histogram)")
+	}
+	return nil
+}
+
+// RemoteFilter returns filter of remote addresses by AWS service and region (remote-service and remote-region flags)
+func (f QueryFlags) RemoteFilter() ipranges.Filter {
+	return ipranges.Filter{Services: splitList(f.remoteService), Regions: splitList(f.remoteRegion)}
+}
+
+// IsClientSide returns true if records are filtered (remote service and region) or merged after the query, query
+// returns up to max limit records and limit flag is applied to the client-side results
+func (f QueryFlags) IsClientSide() bool {
+	return f.MergeFlows || !f.RemoteFilter().IsEmpty()
+}
+
+// IPRangesPath returns path of AWS ip-ranges.json, ip-ranges flag or the file cached by update-ranges command
+func (f QueryFlags) IPRangesPath() (string, error) {
+	if f.ipRanges != "" {
+		return f.ipRanges, nil
+	}
+	return ipranges.CachePath()
+}
+
+// ResultLimit returns number of filtered or merged flows to print (limit flag), 0 if all results are requested
+func (f QueryFlags) ResultLimit() int {
+	if f.all {
+		return 0
+	}
+	return f.limit
+}
+
+// noiseProfile returns exclusions from the flags merged with the named noise profiles
+func (f QueryFlags) noiseProfile() (config.NoiseProfile, error) {
+	profile, err := f.noise.GetNoiseProfile()
+	if err != nil {
+		return config.NoiseProfile{}, err
+	}
+
+	names := splitList(f.noiseNames)
+	if len(names) == 0 {
+		return profile, nil
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return config.NoiseProfile{}, err
+	}
+	for _, name := range names {
+		p, err := cfg.GetNoiseProfile(name)
+		if err != nil {
+			return config.NoiseProfile{}, err
+		}
+		profile = profile.Merge(p)
+	}
+	return profile, nil
+}
+
+// tcpFlagsFilters returns tcp flags from tcp-flags flag and presets, every returned filter has to match
+func (f QueryFlags) tcpFlagsFilters() ([]query.TcpFlags, error) {
+	var specs []string
+	if f.tcpFlags != "" {
+		specs = append(specs, f.tcpFlags)
+	}
+	if f.synOnly {
+		specs = append(specs, "SYN,!ACK")
+	}
+	if f.rst {
+		specs = append(specs, "RST")
+	}
+	if f.fin {
+		specs = append(specs, "FIN")
+	}
+
+	var out []query.TcpFlags
+	for _, spec := range specs {
+		flags, err := query.ParseTcpFlags(spec)
+		if err != nil {
+			return nil, fmt.Errorf("tcp-flags: %w", err)
+		}
+		out = append(out, flags)
+	}
+	return out, nil
+}
+
+// parsePorts parses ports flag value, empty value or negative number (previously used to disable the flag) means
+// all ports and nil is returned
+func parsePorts(in string) (query.Ports, error) {
+	if in == "" {
+		return nil, nil
+	}
+	if n, err := strconv.Atoi(in); err == nil && n < 0 {
+		return nil, nil
+	}
+	return query.ParsePorts(in)
+}
+
+func (f QueryFlags) validateAddresses() error {
+	addresses := []struct {
+		flag  string
+		value string
+	}{
+		{"addr", f.addr},
+		{"src-addr", f.srcAddr},
+		{"pkt-src-addr", f.pktSrcAddr},
+		{"dst-addr", f.dstAddr},
+		{"pkt-dst-addr", f.pktDstAddr},
+	}
+	for _, addr := range addresses {
+		if addr.value == "" {
+			continue
+		}
+		if err := query.ValidateAddress(addr.value); err != nil {
+			return fmt.Errorf("%s: %w", addr.flag, err)
+		}
+	}
+	return nil
+}
+
+// maxHistogramBuckets is maximum number of histogram time buckets, Logs Insights returns at most 10,000 results
+const maxHistogramBuckets = 10000
+
+// aggregation returns aggregation from top, sum, count and histogram flags, duration is query time range used to
+// check number of histogram buckets
+func (f QueryFlags) aggregation(duration time.Duration) (query.Aggregation, error) {
+	if f.all {
+		return query.Aggregation{}, errors.New("all cannot be used with aggregation (top, sum, count, histogram)")
+	}
+	if f.Follow {
+		return query.Aggregation{}, errors.New("follow cannot be used with aggregation (top, sum, count, histogram)")
+	}
+
+	var sortBy string
+	switch {
+	case f.sum != "":
+		if f.sum != "bytes" && f.sum != "packets" {
+			return query.Aggregation{}, fmt.Errorf("invalid sum %q, supported values: bytes, packets", f.sum)
+		}
+		sortBy = f.sum
+	case f.count:
+		sortBy = "count"
+	}
+	if f.histogram == "" {
+		return query.NewAggregation(splitList(f.top), sortBy)
+	}
+
+	bin, err := query.ParseBin(f.histogram)
+	if err != nil {
+		return query.Aggregation{}, fmt.Errorf("histogram: %w", err)
+	}
+	if buckets := int(duration / bin); buckets > maxHistogramBuckets {
+		return query.Aggregation{}, fmt.Errorf("histogram: %d buckets of %s, use larger bucket or shorter time range (at most %d buckets)", buckets, f.histogram, maxHistogramBuckets)
+	}
+	return query.NewHistogram(bin, splitList(f.top), sortBy)
+}
+
+// timeRange returns start and end time from start and end flags. Missing start defaults to 'minutes' before end and
+// missing end defaults to now
+func (f QueryFlags) timeRange(now time.Time) (time.Time, time.Time, error) {
+	end := now
+	if f.end != "" {
+		t, err := query.ParseTime(f.end, now)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("end: %w", err)
+		}
+		end = t
+	}
+
+	start := end.Add(time.Duration(-f.sinceMinutes) * time.Minute)
+	if f.start != "" {
+		t, err := query.ParseTime(f.start, now)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("start: %w", err)
+		}
+		start = t
+	}
+
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start %s is not before end %s", start.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	return start, end, nil
 }
 
 func InitPersistentQueryFlags(cmd *cobra.Command, flags *QueryFlags) {
@@ -82,17 +502,125 @@ func InitPersistentQueryFlags(cmd *cobra.Command, flags *QueryFlags) {
 		getBoolEnv("PRETTY", false),
 		"whether to enhance flow logs with names",
 	)
+	cmd.PersistentFlags().BoolVar(
+		&flags.Conversations,
+		"conversations",
+		getBoolEnv("CONVERSATIONS", false),
+		"join records of the same connection across network interfaces and NAT, one row per conversation with per-hop bytes",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&flags.MergeFlows,
+		"merge-flows",
+		getBoolEnv("MERGE_FLOWS", false),
+		"merge records of the same flow (ni and 5-tuple) in consecutive aggregation windows, limit applies to merged flows",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&flags.Follow,
+		"follow",
+		getBoolEnv("FOLLOW", false),
+		"stream new flow logs as they arrive (live tail), time range and limit flags are ignored",
+	)
+	cmd.PersistentFlags().DurationVar(
+		&flags.Timeout,
+		"timeout",
+		getDurationEnv("TIMEOUT", 30*time.Minute),
+		"cancel query that does not complete within the timeout (0 disables timeout), ignored with follow",
+	)
+	cmd.PersistentFlags().Float64Var(
+		&flags.PricePerGB,
+		"price-per-gb",
+		getFloat64Env("PRICE_PER_GB", 0.005),
+		"Logs Insights price per GB of scanned data (USD), used for query cost estimate",
+	)
+	cmd.PersistentFlags().Float64Var(
+		&flags.MaxScanGB,
+		"max-scan-gb",
+		getFloat64Env("MAX_SCAN_GB", 0),
+		"refuse to start queries expected to scan more GB and stop queries that scan more GB (0 disables the limit)",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.output,
+		"output",
+		getStringEnv("OUTPUT", string(out.FormatTable)),
+		"output format - table, json, ndjson, csv, markdown",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.preset,
+		presetFlag,
+		getStringEnv("PRESET", ""),
+		"apply query flags from the named preset (see preset command), flags on command line take precedence",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.insights,
+		"insights",
+		getStringEnv("INSIGHTS", ""),
+		"raw Logs Insights pipeline appended to the query, pipeline starting with 'fields' replaces generated fields",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.logGroups,
+		"log-group",
+		getStringEnv("LOG_GROUP", ""),
+		"comma separated CloudWatch log group names with flow logs (flow logs do not have to be created by cli)",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.columns,
+		"columns",
+		getStringEnv("COLUMNS", ""),
+		fmt.Sprintf("comma separated optional table columns - %s", strings.Join(OptionalColumns, ", ")),
+	)
 	cmd.PersistentFlags().IntVar(
 		&flags.limit,
 		"limit",
 		getIntEnv("LIMIT", 100),
 		"number of returned results",
 	)
+	cmd.PersistentFlags().BoolVar(
+		&flags.all,
+		"all",
+		getBoolEnv("ALL", false),
+		"return all results (ignores limit), time range is split to smaller concurrent queries",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.top,
+		"top",
+		getStringEnv("TOP", ""),
+		fmt.Sprintf("aggregate results by comma separated keys - %s", strings.Join(query.AggregationKeys(), ", ")),
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.sum,
+		"sum",
+		getStringEnv("SUM", ""),
+		"aggregate results and sort them by sum of bytes or packets",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&flags.count,
+		"count",
+		getBoolEnv("COUNT", false),
+		"aggregate results and sort them by number of flow log records",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.histogram,
+		"histogram",
+		getStringEnv("HISTOGRAM", ""),
+		"aggregate results to time buckets (1m, 5m, 1h) and chart bytes, or packets (sum) or records (count), split by top keys (action, direction)",
+	)
 	cmd.PersistentFlags().IntVar(
 		&flags.sinceMinutes,
 		"minutes",
 		getIntEnv("MINUTES", 60),
-		"minutes 'ago' to search logs",
+		"minutes 'ago' to search logs, ignored if start is set",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.start,
+		"start",
+		getStringEnv("START", ""),
+		"start time - RFC3339, date (2006-01-02 [15:04[:05]]) or relative duration (-3h, 2d)",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.end,
+		"end",
+		getStringEnv("END", ""),
+		"end time - RFC3339, date (2006-01-02 [15:04[:05]]) or relative duration (-3h, 2d), defaults to now",
 	)
 	cmd.PersistentFlags().StringVar(
 		&flags.niId,
@@ -100,11 +628,77 @@ func InitPersistentQueryFlags(cmd *cobra.Command, flags *QueryFlags) {
 		getStringEnv("NI_ID", ""),
 		"network interface id",
 	)
+	cmd.PersistentFlags().StringVar(
+		&flags.subnetId,
+		"subnet-id",
+		getStringEnv("SUBNET_ID", ""),
+		"subnet id",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.instanceId,
+		"instance-id",
+		getStringEnv("INSTANCE_ID", ""),
+		"instance id",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.trafficType,
+		"type",
+		getStringEnv("TYPE", ""),
+		fmt.Sprintf("traffic type - %s", strings.Join(query.TrafficTypes, ", ")),
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.awsService,
+		"aws-service",
+		getStringEnv("AWS_SERVICE", ""),
+		"packet source or destination AWS service (S3, DYNAMODB, EC2, AMAZON, ...)",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.remoteService,
+		"remote-service",
+		getStringEnv("REMOTE_SERVICE", ""),
+		"remote address AWS service from ip ranges, comma separated services (S3, EC2, AMAZON, ...), filtered client-side",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.remoteRegion,
+		"remote-region",
+		getStringEnv("REMOTE_REGION", ""),
+		"remote address AWS region from ip ranges, comma separated regions (us-east-1, GLOBAL, ...), filtered client-side",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.ipRanges,
+		"ip-ranges",
+		getStringEnv("IP_RANGES", ""),
+		"path to AWS ip-ranges.json for remote service and region, defaults to file cached by update-ranges command",
+	)
 	cmd.PersistentFlags().StringVar(
 		&flags.protocol,
 		"protocol",
 		getStringEnv("PROTOCOL", ""),
-		"protocol",
+		"protocol, comma separated keywords or numbers (tcp,udp)",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.tcpFlags,
+		"tcp-flags",
+		getStringEnv("TCP_FLAGS", ""),
+		"comma separated tcp flags (FIN, SYN, RST, ACK), prefix with ! for flags that are not set (SYN,!ACK)",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&flags.synOnly,
+		"syn-only",
+		getBoolEnv("SYN_ONLY", false),
+		"connection attempts that were not acknowledged, same as --tcp-flags SYN,!ACK",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&flags.rst,
+		"rst",
+		getBoolEnv("RST", false),
+		"reset connections, same as --tcp-flags RST",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&flags.fin,
+		"fin",
+		getBoolEnv("FIN", false),
+		"finished connections, same as --tcp-flags FIN",
 	)
 	cmd.PersistentFlags().BoolVar(
 		&flags.ingress,
@@ -130,52 +724,65 @@ func InitPersistentQueryFlags(cmd *cobra.Command, flags *QueryFlags) {
 		getBoolEnv("REJECT", false),
 		"rejected traffic",
 	)
-	cmd.PersistentFlags().IntVar(
+	cmd.PersistentFlags().StringVar(
 		&flags.port,
 		"port",
-		getIntEnv("PORT", -1),
-		"port - source or destination, negative value means all ports",
+		getStringEnv("PORT", ""),
+		"port - source or destination, comma separated ports and ranges (22,3389,1024-65535)",
 	)
 	cmd.PersistentFlags().StringVar(
 		&flags.addr,
 		"addr",
 		getStringEnv("ADDR", ""),
-		"address - source, destination or packet",
+		"address - source, destination or packet, IP or CIDR",
 	)
-	cmd.PersistentFlags().IntVar(
+	cmd.PersistentFlags().StringVar(
 		&flags.srcPort,
 		"src-port",
-		getIntEnv("SRC_PORT", -1),
-		"source port, negative value means all ports",
+		getStringEnv("SRC_PORT", ""),
+		"source port, comma separated ports and ranges (22,3389,1024-65535)",
 	)
 	cmd.PersistentFlags().StringVar(
 		&flags.srcAddr,
 		"src-addr",
 		getStringEnv("SRC_ADDR", ""),
-		"source address",
+		"source address, IP or CIDR",
 	)
 	cmd.PersistentFlags().StringVar(
 		&flags.pktSrcAddr,
 		"pkt-src-addr",
 		getStringEnv("PKT_SRC_ADDR", ""),
-		"packet source address",
+		"packet source address, IP or CIDR",
 	)
-	cmd.PersistentFlags().IntVar(
+	cmd.PersistentFlags().StringVar(
 		&flags.dstPort,
 		"dst-port",
-		getIntEnv("DST_PORT", -1),
-		"destination port, negative value means all ports",
+		getStringEnv("DST_PORT", ""),
+		"destination port, comma separated ports and ranges (22,3389,1024-65535)",
 	)
 	cmd.PersistentFlags().StringVar(
 		&flags.dstAddr,
 		"dst-addr",
 		getStringEnv("DST_ADDR", ""),
-		"destination address",
+		"destination address, IP or CIDR",
 	)
 	cmd.PersistentFlags().StringVar(
 		&flags.pktDstAddr,
 		"pkt-dst-addr",
 		getStringEnv("PKT_DST_ADDR", ""),
-		"packet destination address",
+		"packet destination address, IP or CIDR",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.where,
+		"where",
+		getStringEnv("WHERE", ""),
+		"filter expression, e.g. 'dstPort in (22,3389) and not srcAddr in 10.0.0.0/8 or action = REJECT'",
+	)
+	cmd.PersistentFlags().StringVar(
+		&flags.noiseNames,
+		"noise",
+		getStringEnv("NOISE", ""),
+		"exclude noise using comma separated noise profile names (see noise command)",
 	)
+	initNoiseFlags(cmd.PersistentFlags(), &flags.noise)
 }
@@ -20,13 +20,14 @@ var (
 	}
 )
 
-func runCreate(_ *cobra.Command, _ []string) {
+func runCreate(cmd *cobra.Command, _ []string) {
+	ctx := cmd.Context()
 	logger := flag.Global.Logger()
 	client := aws.NewClient(logger, flag.Global.AWSConfig())
 
-	selectedVPC := prompt.SelectVPC(prompt.ListVPCs(client), false)
-	selectedInstances := prompt.SelectInstances(prompt.ListInstances(client, selectedVPC.Id), true)
-	logGroup, err := client.CreateInstanceFlowLogs(selectedInstances)
+	selectedVPC := prompt.SelectVPC(prompt.ListVPCs(ctx, client), false)
+	selectedInstances := prompt.SelectInstances(prompt.ListInstances(ctx, client, selectedVPC.Id), true)
+	logGroup, err := client.CreateInstanceFlowLogs(ctx, selectedInstances)
 	if err != nil {
 		fmt.Printf("create flow logs: %v\n", err)
 		os.Exit(1)
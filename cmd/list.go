@@ -22,11 +22,11 @@ func init() {
 	Root.AddCommand(list)
 }
 
-func runList(_ *cobra.Command, _ []string) {
+func runList(cmd *cobra.Command, _ []string) {
 	logger := flag.Global.Logger()
 	client := aws.NewClient(logger, flag.Global.AWSConfig())
 
-	flowLogs, err := client.ListFlowLogs(aws.FlowLogTypeAll)
+	flowLogs, err := client.ListFlowLogs(cmd.Context(), aws.FlowLogTypeAll)
 	if err != nil {
 		fmt.Printf("list flow logs: %v\n", err)
 		os.Exit(1)
@@ -20,12 +20,13 @@ var (
 	}
 )
 
-func runDelete(_ *cobra.Command, _ []string) {
+func runDelete(cmd *cobra.Command, _ []string) {
+	ctx := cmd.Context()
 	logger := flag.Global.Logger()
 	client := aws.NewClient(logger, flag.Global.AWSConfig())
 
-	selectedFlowLogs := prompt.SelectFlowLogs(prompt.ListFlowLogs(client, aws.FlowLogTypeNatGateway), true)
-	if err := client.DeleteResources(selectedFlowLogs); err != nil {
+	selectedFlowLogs := prompt.SelectFlowLogs(prompt.ListFlowLogs(ctx, client, aws.FlowLogTypeNatGateway), true)
+	if err := client.DeleteResources(ctx, selectedFlowLogs); err != nil {
 		fmt.Printf("delete flow logs: %v\n", err)
 		os.Exit(1)
 	}
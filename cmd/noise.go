@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pete911/flowlogs/cmd/flag"
+	"github.com/pete911/flowlogs/cmd/out"
+	"github.com/pete911/flowlogs/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	Noise = &cobra.Command{
+		Use:   "noise",
+		Short: "manage noise profiles (named exclusions applied to queries with --noise flag)",
+		Long:  "",
+	}
+
+	NoiseSave = &cobra.Command{
+		Use:   "save <name>",
+		Short: "save noise profile from --not-* flags, existing profile is replaced",
+		Long:  "",
+		Args:  cobra.ExactArgs(1),
+		Run:   runNoiseSave,
+	}
+
+	NoiseList = &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "list noise profiles",
+		Long:    "",
+		Run:     runNoiseList,
+	}
+
+	NoiseDelete = &cobra.Command{
+		Use:   "delete <name>",
+		Short: "delete noise profile",
+		Long:  "",
+		Args:  cobra.ExactArgs(1),
+		Run:   runNoiseDelete,
+	}
+)
+
+func init() {
+	flag.InitNoiseFlags(NoiseSave, &flag.Noise)
+	Root.AddCommand(Noise)
+	Noise.AddCommand(NoiseSave)
+	Noise.AddCommand(NoiseList)
+	Noise.AddCommand(NoiseDelete)
+}
+
+func runNoiseSave(_ *cobra.Command, args []string) {
+	profile, err := flag.Noise.GetNoiseProfile()
+	if err != nil {
+		fmt.Printf("noise profile: %v\n", err)
+		os.Exit(1)
+	}
+	if profile.IsEmpty() {
+		fmt.Println("noise profile: at least one --not-* flag is required")
+		os.Exit(1)
+	}
+
+	cfg := loadConfig()
+	if err := cfg.SetNoiseProfile(args[0], profile).Save(); err != nil {
+		fmt.Printf("save noise profile: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("noise profile %s saved\n", args[0])
+}
+
+func runNoiseList(_ *cobra.Command, _ []string) {
+	cfg := loadConfig()
+	logger := flag.Global.Logger()
+	table := out.NewTable(logger, os.Stdout)
+	table.AddRow("NAME", "ADDRESSES", "PORTS", "INTERFACE IDS", "PROTOCOLS")
+	for _, name := range cfg.NoiseProfileNames() {
+		profile, _ := cfg.GetNoiseProfile(name)
+		table.AddRow(
+			name,
+			strings.Join(profile.Addresses, ","),
+			strings.Join(profile.Ports, ","),
+			strings.Join(profile.InterfaceIds, ","),
+			strings.Join(profile.Protocols, ","),
+		)
+	}
+	table.Print()
+}
+
+func runNoiseDelete(_ *cobra.Command, args []string) {
+	cfg, err := loadConfig().DeleteNoiseProfile(args[0])
+	if err != nil {
+		fmt.Printf("delete noise profile: %v\n", err)
+		os.Exit(1)
+	}
+	if err := cfg.Save(); err != nil {
+		fmt.Printf("delete noise profile: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("noise profile %s deleted\n", args[0])
+}
+
+func loadConfig() config.Config {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("load config: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg
+}
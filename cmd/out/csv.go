@@ -0,0 +1,33 @@
+package out
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+type CSV struct {
+	logger *slog.Logger
+	writer *csv.Writer
+}
+
+func NewCSV(logger *slog.Logger, output io.Writer) CSV {
+	return CSV{
+		logger: logger,
+		writer: csv.NewWriter(output),
+	}
+}
+
+func (c CSV) AddRow(columns ...string) {
+	if err := c.writer.Write(columns); err != nil {
+		c.logger.Error(fmt.Sprintf("csv: add row: %v", err))
+	}
+}
+
+func (c CSV) Print() {
+	c.writer.Flush()
+	if err := c.writer.Error(); err != nil {
+		c.logger.Error(fmt.Sprintf("csv: print: %v", err))
+	}
+}
@@ -0,0 +1,129 @@
+package out
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// JSON writes all rows as a single json document - {"results": [{"<header>": "<value>", ...}, ...]}, additional top
+// level fields (e.g. query statistics) can be set before the document is printed
+type JSON struct {
+	logger *slog.Logger
+	output io.Writer
+	keys   []string
+	rows   []json.RawMessage
+	fields map[string]any
+}
+
+func NewJSON(logger *slog.Logger, output io.Writer) *JSON {
+	return &JSON{
+		logger: logger,
+		output: output,
+	}
+}
+
+func (j *JSON) AddRow(columns ...string) {
+	if j.keys == nil {
+		j.keys = columns
+		return
+	}
+	row, err := toJSONObject(j.keys, columns)
+	if err != nil {
+		j.logger.Error(fmt.Sprintf("json: add row: %v", err))
+		return
+	}
+	j.rows = append(j.rows, row)
+}
+
+// Set sets top level field of the json document, "results" field cannot be set
+func (j *JSON) Set(key string, value any) {
+	if j.fields == nil {
+		j.fields = make(map[string]any)
+	}
+	j.fields[key] = value
+}
+
+func (j *JSON) Print() {
+	// make sure we print empty list instead of null
+	rows := j.rows
+	if rows == nil {
+		rows = []json.RawMessage{}
+	}
+
+	document := map[string]any{}
+	for k, v := range j.fields {
+		document[k] = v
+	}
+	document["results"] = rows
+
+	b, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		j.logger.Error(fmt.Sprintf("json: print: %v", err))
+		return
+	}
+	if _, err := fmt.Fprintln(j.output, string(b)); err != nil {
+		j.logger.Error(fmt.Sprintf("json: print: %v", err))
+	}
+}
+
+// NDJSON writes every row as a separate json object on a single line, rows are written as they are added
+type NDJSON struct {
+	logger *slog.Logger
+	output io.Writer
+	keys   []string
+}
+
+func NewNDJSON(logger *slog.Logger, output io.Writer) *NDJSON {
+	return &NDJSON{
+		logger: logger,
+		output: output,
+	}
+}
+
+func (n *NDJSON) AddRow(columns ...string) {
+	if n.keys == nil {
+		n.keys = columns
+		return
+	}
+	row, err := toJSONObject(n.keys, columns)
+	if err != nil {
+		n.logger.Error(fmt.Sprintf("ndjson: add row: %v", err))
+		return
+	}
+	if _, err := fmt.Fprintln(n.output, string(row)); err != nil {
+		n.logger.Error(fmt.Sprintf("ndjson: add row: %v", err))
+	}
+}
+
+func (n *NDJSON) Print() {}
+
+// toJSONObject creates json object from keys and values, keeping the order of the keys
+func toJSONObject(keys, values []string) (json.RawMessage, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range keys {
+		var value string
+		if i < len(values) {
+			value = values[i]
+		}
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,45 @@
+package out
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Markdown writes rows as markdown (github flavoured) table, that can be pasted to tickets or documents
+type Markdown struct {
+	logger  *slog.Logger
+	output  io.Writer
+	columns int
+}
+
+func NewMarkdown(logger *slog.Logger, output io.Writer) *Markdown {
+	return &Markdown{
+		logger: logger,
+		output: output,
+	}
+}
+
+func (m *Markdown) AddRow(columns ...string) {
+	escaped := make([]string, len(columns))
+	for i, column := range columns {
+		escaped[i] = escapeMarkdown(column)
+	}
+	line := fmt.Sprintf("| %s |", strings.Join(escaped, " | "))
+
+	// first row is header, add separator line
+	if m.columns == 0 {
+		m.columns = len(columns)
+		line = fmt.Sprintf("%s\n|%s", line, strings.Repeat(" --- |", len(columns)))
+	}
+	if _, err := fmt.Fprintln(m.output, line); err != nil {
+		m.logger.Error(fmt.Sprintf("markdown: add row: %v", err))
+	}
+}
+
+func (m *Markdown) Print() {}
+
+func escapeMarkdown(in string) string {
+	return strings.ReplaceAll(in, "|", `\|`)
+}
@@ -0,0 +1,45 @@
+package out
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Preview writes block of lines (e.g. partial results of running query) that is replaced by every update and removed
+// once the final output is ready. Nothing is written if the output is not terminal, because written lines cannot be
+// removed from a file. Line wrapping is disabled while the preview is written, so every line takes exactly one terminal
+// row and the whole block can be removed (long lines are cut at terminal width).
+type Preview struct {
+	output   *os.File
+	terminal bool
+	lines    int
+}
+
+func NewPreview(output *os.File) *Preview {
+	return &Preview{output: output, terminal: isTerminal(output)}
+}
+
+// IsTerminal returns true if the preview is written, output is terminal
+func (p *Preview) IsTerminal() bool {
+	return p.terminal
+}
+
+// Update replaces previous preview with the text, text should end with new line
+func (p *Preview) Update(text string) {
+	if !p.terminal {
+		return
+	}
+	p.Clear()
+	fmt.Fprintf(p.output, "\033[?7l%s\033[?7h", text)
+	p.lines = strings.Count(text, "\n")
+}
+
+// Clear removes preview, it has to be called before the final output is written
+func (p *Preview) Clear() {
+	if !p.terminal || p.lines == 0 {
+		return
+	}
+	fmt.Fprintf(p.output, "\033[%dA\r\033[J", p.lines)
+	p.lines = 0
+}
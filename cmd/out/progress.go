@@ -0,0 +1,43 @@
+package out
+
+import (
+	"fmt"
+	"os"
+)
+
+// Progress writes single status line that is overwritten by every update. Nothing is written if the output is not
+// terminal (e.g. redirected to file), so the status line does not end up in the output.
+type Progress struct {
+	output   *os.File
+	terminal bool
+	visible  bool
+}
+
+func NewProgress(output *os.File) *Progress {
+	return &Progress{output: output, terminal: isTerminal(output)}
+}
+
+func (p *Progress) Update(line string) {
+	if !p.terminal {
+		return
+	}
+	fmt.Fprintf(p.output, "\r\033[K%s", line)
+	p.visible = true
+}
+
+// Clear removes status line, it has to be called before anything else is written to the terminal
+func (p *Progress) Clear() {
+	if !p.terminal || !p.visible {
+		return
+	}
+	fmt.Fprint(p.output, "\r\033[K")
+	p.visible = false
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
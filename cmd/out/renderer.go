@@ -0,0 +1,64 @@
+package out
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type Format string
+
+const (
+	FormatTable    Format = "table"
+	FormatJSON     Format = "json"
+	FormatNDJSON   Format = "ndjson"
+	FormatCSV      Format = "csv"
+	FormatMarkdown Format = "markdown"
+)
+
+var Formats = []Format{FormatTable, FormatJSON, FormatNDJSON, FormatCSV, FormatMarkdown}
+
+func ParseFormat(in string) (Format, error) {
+	for _, f := range Formats {
+		if strings.EqualFold(string(f), in) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("invalid output format %q, supported formats: %s", in, strings.Join(formatNames(), ", "))
+}
+
+// IsMachineReadable returns true for formats that are meant to be consumed by other tools (jq, spreadsheets, ...)
+// rather than read by humans. Machine-readable output should include raw fields as well as derived columns.
+func (f Format) IsMachineReadable() bool {
+	return f == FormatJSON || f == FormatNDJSON || f == FormatCSV
+}
+
+func formatNames() []string {
+	var out []string
+	for _, f := range Formats {
+		out = append(out, string(f))
+	}
+	return out
+}
+
+// Renderer writes rows in specific output format. The first row added is header (column names or keys).
+type Renderer interface {
+	AddRow(columns ...string)
+	Print()
+}
+
+func NewRenderer(logger *slog.Logger, output io.Writer, format Format) Renderer {
+	switch format {
+	case FormatJSON:
+		return NewJSON(logger, output)
+	case FormatNDJSON:
+		return NewNDJSON(logger, output)
+	case FormatCSV:
+		return NewCSV(logger, output)
+	case FormatMarkdown:
+		return NewMarkdown(logger, output)
+	default:
+		return NewTable(logger, output)
+	}
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pete911/flowlogs/cmd/flag"
+	"github.com/pete911/flowlogs/cmd/out"
+	"github.com/spf13/cobra"
+)
+
+var (
+	Preset = &cobra.Command{
+		Use:   "preset",
+		Short: "manage query presets (named query flags applied to queries with --preset flag)",
+		Long:  "",
+	}
+
+	PresetSave = &cobra.Command{
+		Use:   "save <name>",
+		Short: "save query flags set on command line as preset, existing preset is replaced",
+		Long:  "",
+		Args:  cobra.ExactArgs(1),
+		Run:   runPresetSave,
+	}
+
+	PresetList = &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "list query presets",
+		Long:    "",
+		Run:     runPresetList,
+	}
+
+	PresetDelete = &cobra.Command{
+		Use:   "delete <name>",
+		Short: "delete query preset",
+		Long:  "",
+		Args:  cobra.ExactArgs(1),
+		Run:   runPresetDelete,
+	}
+)
+
+func init() {
+	flag.InitPersistentQueryFlags(PresetSave, &flag.Query)
+	Root.AddCommand(Preset)
+	Preset.AddCommand(PresetSave)
+	Preset.AddCommand(PresetList)
+	Preset.AddCommand(PresetDelete)
+}
+
+func runPresetSave(cmd *cobra.Command, args []string) {
+	preset := flag.PresetFromFlags(cmd)
+	if len(preset) == 0 {
+		fmt.Println("preset: at least one query flag is required")
+		os.Exit(1)
+	}
+	// validate flags, the same way as they are validated by query command
+	if _, err := flag.Query.GetQuery(); err != nil {
+		fmt.Printf("preset: %v\n", err)
+		os.Exit(1)
+	}
+
+	cfg := loadConfig()
+	if err := cfg.SetPreset(args[0], preset).Save(); err != nil {
+		fmt.Printf("save preset: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("preset %s saved: %s\n", args[0], preset)
+}
+
+func runPresetList(_ *cobra.Command, _ []string) {
+	cfg := loadConfig()
+	logger := flag.Global.Logger()
+	table := out.NewTable(logger, os.Stdout)
+	table.AddRow("NAME", "FLAGS")
+	for _, name := range cfg.PresetNames() {
+		preset, _ := cfg.GetPreset(name)
+		table.AddRow(name, preset.String())
+	}
+	table.Print()
+}
+
+func runPresetDelete(_ *cobra.Command, args []string) {
+	cfg, err := loadConfig().DeletePreset(args[0])
+	if err != nil {
+		fmt.Printf("delete preset: %v\n", err)
+		os.Exit(1)
+	}
+	if err := cfg.Save(); err != nil {
+		fmt.Printf("delete preset: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("preset %s deleted\n", args[0])
+}
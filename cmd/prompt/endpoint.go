@@ -1,6 +1,7 @@
 package prompt
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -8,14 +9,14 @@ import (
 	"github.com/pete911/flowlogs/internal/aws/ec2"
 )
 
-func ListVPCEndpoints(client aws.Client) ec2.VPCEndpoints {
-	endpoints, err := client.ListVPCEndpoints()
+func ListVPCEndpoints(ctx context.Context, client aws.Client) ec2.VPCEndpoints {
+	endpoints, err := client.ListVPCEndpoints(ctx)
 	if err != nil {
 		fmt.Printf("list vpc endpoints: %v\n", err)
 		os.Exit(1)
 	}
 
-	existingVPCFlowLogs, err := client.ListFlowLogs(aws.FlowLogTypeVPCEndpoint)
+	existingVPCFlowLogs, err := client.ListFlowLogs(ctx, aws.FlowLogTypeVPCEndpoint)
 	if err != nil {
 		fmt.Printf("list vpc endpoint flow logs: %v\n", err)
 		os.Exit(1)
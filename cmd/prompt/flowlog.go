@@ -1,6 +1,7 @@
 package prompt
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -8,8 +9,8 @@ import (
 	"github.com/pete911/flowlogs/internal/aws/ec2"
 )
 
-func ListFlowLogs(client aws.Client, flowLogType aws.FlowLogType) ec2.FlowLogs {
-	flowLogs, err := client.ListFlowLogs(flowLogType)
+func ListFlowLogs(ctx context.Context, client aws.Client, flowLogType aws.FlowLogType) ec2.FlowLogs {
+	flowLogs, err := client.ListFlowLogs(ctx, flowLogType)
 	if err != nil {
 		fmt.Printf("delete flow logs: %v\n", err)
 		os.Exit(1)
@@ -58,3 +59,58 @@ func SelectFlowLogs(flowLogs ec2.FlowLogs, confirm bool) ec2.FlowLogs {
 	}
 	return selectedFlowLogs
 }
+
+// SelectFlowLogGroups lets user select one or more flow log groups (flow logs with the same name) and returns flow
+// logs from all the selected groups
+func SelectFlowLogGroups(flowLogs ec2.FlowLogs, confirm bool) ec2.FlowLogs {
+	if len(flowLogs) == 0 {
+		fmt.Println("no flow logs found")
+		os.Exit(1)
+	}
+
+	names, flowLogsByName := flowLogs.GetByNames()
+	var items []string
+	for _, name := range names {
+		items = append(items, fmt.Sprintf("%s [%d flow logs]", name, len(flowLogsByName[name])))
+	}
+	label := fmt.Sprintf("select flow logs [%d groups]:", len(flowLogsByName))
+
+	var selectedFlowLogs ec2.FlowLogs
+	for _, i := range MultiSelect(label, items) {
+		selectedFlowLogs = append(selectedFlowLogs, flowLogsByName[names[i]]...)
+	}
+	fmt.Println("selected flow logs:")
+	for _, selectedFlowLog := range selectedFlowLogs {
+		fmt.Println(selectedFlowLog)
+	}
+	if confirm {
+		Confirm("selected flow logs, continue")
+	}
+	return selectedFlowLogs
+}
+
+// SelectLogGroupFlowLogs lets user select one or more log groups and returns flow logs delivered to the selected log
+// groups
+func SelectLogGroupFlowLogs(flowLogs ec2.FlowLogs) ec2.FlowLogs {
+	if len(flowLogs) == 0 {
+		fmt.Println("no flow logs found")
+		os.Exit(1)
+	}
+
+	names, flowLogsByLogGroup := flowLogs.GetByLogGroupNames()
+	var items []string
+	for _, name := range names {
+		items = append(items, fmt.Sprintf("%s [%d flow logs]", name, len(flowLogsByLogGroup[name])))
+	}
+	label := fmt.Sprintf("select log groups [%d log groups]:", len(flowLogsByLogGroup))
+
+	var selectedFlowLogs ec2.FlowLogs
+	for _, i := range MultiSelect(label, items) {
+		selectedFlowLogs = append(selectedFlowLogs, flowLogsByLogGroup[names[i]]...)
+	}
+	fmt.Println("selected flow logs:")
+	for _, selectedFlowLog := range selectedFlowLogs {
+		fmt.Println(selectedFlowLog)
+	}
+	return selectedFlowLogs
+}
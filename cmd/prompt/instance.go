@@ -1,6 +1,7 @@
 package prompt
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -8,14 +9,14 @@ import (
 	"github.com/pete911/flowlogs/internal/aws/ec2"
 )
 
-func ListInstances(client aws.Client, vpcId string) ec2.Instances {
-	instances, err := client.ListInstances(vpcId)
+func ListInstances(ctx context.Context, client aws.Client, vpcId string) ec2.Instances {
+	instances, err := client.ListInstances(ctx, vpcId)
 	if err != nil {
 		fmt.Printf("list instances: %v\n", err)
 		os.Exit(1)
 	}
 
-	existingInstanceFlowLogs, err := client.ListFlowLogs(aws.FlowLogTypeInstance)
+	existingInstanceFlowLogs, err := client.ListFlowLogs(ctx, aws.FlowLogTypeInstance)
 	if err != nil {
 		fmt.Printf("list instance flow logs: %v\n", err)
 		os.Exit(1)
@@ -1,6 +1,7 @@
 package prompt
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -8,14 +9,14 @@ import (
 	"github.com/pete911/flowlogs/internal/aws/ec2"
 )
 
-func ListNatGateways(client aws.Client, vpcId string) ec2.NatGateways {
-	sgs, err := client.ListNatGateways(vpcId)
+func ListNatGateways(ctx context.Context, client aws.Client, vpcId string) ec2.NatGateways {
+	sgs, err := client.ListNatGateways(ctx, vpcId)
 	if err != nil {
 		fmt.Printf("list nat gateways: %v\n", err)
 		os.Exit(1)
 	}
 
-	existingNATFlowLogs, err := client.ListFlowLogs(aws.FlowLogTypeNatGateway)
+	existingNATFlowLogs, err := client.ListFlowLogs(ctx, aws.FlowLogTypeNatGateway)
 	if err != nil {
 		fmt.Printf("list nat gateway flow logs: %v\n", err)
 		os.Exit(1)
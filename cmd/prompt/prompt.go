@@ -41,6 +41,87 @@ func Select(label string, items []string) (int, string) {
 	return i, result
 }
 
+// MultiSelect lets user toggle items and returns indexes of selected items in the items order. Prompt is repeated
+// until user chooses 'done' with at least one item selected.
+func MultiSelect(label string, items []string) []int {
+	if len(items) == 0 {
+		return nil
+	}
+
+	// no need for prompt if there is only one item to chose from
+	if len(items) == 1 {
+		fmt.Printf("%s %s\n", bold(promptui.IconGood), bold(items[0]))
+		return []int{0}
+	}
+
+	// first two prompt items are actions, followed by items
+	const (
+		doneIndex = iota
+		allIndex
+		actions
+	)
+	selected := make([]bool, len(items))
+	var cursor int
+	for {
+		var count int
+		for _, v := range selected {
+			if v {
+				count++
+			}
+		}
+		promptItems := []string{fmt.Sprintf("done [%d selected]", count), "select all"}
+		for i, item := range items {
+			mark := "[ ]"
+			if selected[i] {
+				mark = "[x]"
+			}
+			promptItems = append(promptItems, fmt.Sprintf("%s %s", mark, item))
+		}
+
+		p := promptui.Select{
+			Label:        label,
+			Items:        promptItems,
+			Size:         10,
+			CursorPos:    cursor,
+			HideSelected: true,
+			Searcher: func(input string, index int) bool {
+				item := promptItems[index]
+				name := strings.Replace(strings.ToLower(item), " ", "", -1)
+				input = strings.Replace(strings.ToLower(input), " ", "", -1)
+				return strings.Contains(name, input)
+			},
+		}
+		i, _, err := p.Run()
+		if err != nil {
+			fmt.Printf("%s: %v\n", label, err)
+			os.Exit(1)
+		}
+
+		cursor = i
+		switch i {
+		case doneIndex:
+			if count == 0 {
+				fmt.Println("no item selected")
+				continue
+			}
+			var out []int
+			for j, v := range selected {
+				if v {
+					out = append(out, j)
+					fmt.Printf("%s %s\n", bold(promptui.IconGood), bold(items[j]))
+				}
+			}
+			return out
+		case allIndex:
+			for j := range selected {
+				selected[j] = true
+			}
+		default:
+			selected[i-actions] = !selected[i-actions]
+		}
+	}
+}
+
 func Confirm(label string) {
 	p := promptui.Prompt{
 		Label:     label,
@@ -1,6 +1,7 @@
 package prompt
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -8,14 +9,14 @@ import (
 	"github.com/pete911/flowlogs/internal/aws/ec2"
 )
 
-func ListSecurityGroups(client aws.Client, vpcId string) ec2.SecurityGroups {
-	sgs, err := client.ListSecurityGroups(vpcId)
+func ListSecurityGroups(ctx context.Context, client aws.Client, vpcId string) ec2.SecurityGroups {
+	sgs, err := client.ListSecurityGroups(ctx, vpcId)
 	if err != nil {
 		fmt.Printf("list security groups: %v\n", err)
 		os.Exit(1)
 	}
 
-	existingSGFlowLogs, err := client.ListFlowLogs(aws.FlowLogTypeSecurityGroup)
+	existingSGFlowLogs, err := client.ListFlowLogs(ctx, aws.FlowLogTypeSecurityGroup)
 	if err != nil {
 		fmt.Printf("list security group flow logs: %v\n", err)
 		os.Exit(1)
@@ -1,6 +1,7 @@
 package prompt
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -8,14 +9,14 @@ import (
 	"github.com/pete911/flowlogs/internal/aws/ec2"
 )
 
-func ListSubnets(client aws.Client, vpcId string) ec2.Subnets {
-	subnets, err := client.ListSubnets(vpcId)
+func ListSubnets(ctx context.Context, client aws.Client, vpcId string) ec2.Subnets {
+	subnets, err := client.ListSubnets(ctx, vpcId)
 	if err != nil {
 		fmt.Printf("list subnets: %v\n", err)
 		os.Exit(1)
 	}
 
-	existingSubnetFlowLogs, err := client.ListFlowLogs(aws.FlowLogTypeSubnet)
+	existingSubnetFlowLogs, err := client.ListFlowLogs(ctx, aws.FlowLogTypeSubnet)
 	if err != nil {
 		fmt.Printf("list subnet flow logs: %v\n", err)
 		os.Exit(1)
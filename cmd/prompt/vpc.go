@@ -1,6 +1,7 @@
 package prompt
 
 import (
+	"context"
 	"fmt"
 	"os"
 
@@ -8,14 +9,14 @@ import (
 	"github.com/pete911/flowlogs/internal/aws/ec2"
 )
 
-func ListVPCs(client aws.Client) ec2.VPCs {
-	vpcs, err := client.ListVPCs()
+func ListVPCs(ctx context.Context, client aws.Client) ec2.VPCs {
+	vpcs, err := client.ListVPCs(ctx)
 	if err != nil {
 		fmt.Printf("list vpcs: %v\n", err)
 		os.Exit(1)
 	}
 
-	existingVPCFlowLogs, err := client.ListFlowLogs(aws.FlowLogTypeVPC)
+	existingVPCFlowLogs, err := client.ListFlowLogs(ctx, aws.FlowLogTypeVPC)
 	if err != nil {
 		fmt.Printf("list vpc flow logs: %v\n", err)
 		os.Exit(1)
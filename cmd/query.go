@@ -1,17 +1,25 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pete911/flowlogs/cmd/flag"
 	"github.com/pete911/flowlogs/cmd/out"
 	"github.com/pete911/flowlogs/cmd/prompt"
 	"github.com/pete911/flowlogs/internal/aws"
 	"github.com/pete911/flowlogs/internal/aws/ec2"
+	"github.com/pete911/flowlogs/internal/aws/logs"
 	"github.com/pete911/flowlogs/internal/aws/query"
+	"github.com/pete911/flowlogs/internal/ipranges"
 	"github.com/spf13/cobra"
 )
 
@@ -20,6 +28,7 @@ var (
 		Use:   "query",
 		Short: "query AWS flow logs",
 		Long:  "",
+		Run:   runQuery,
 	}
 
 	QueryInstance = &cobra.Command{
@@ -62,6 +71,21 @@ var (
 		Run:     runQuery,
 	}
 
+	QueryAll = &cobra.Command{
+		Use:   "all",
+		Short: "query all flow logs created by cli",
+		Long:  "",
+		Run:   runQuery,
+	}
+
+	QueryExisting = &cobra.Command{
+		Use:     "existing",
+		Aliases: []string{"cloudwatch"},
+		Short:   "query any flow logs delivered to CloudWatch in the region, including flow logs not created by cli",
+		Long:    "",
+		Run:     runQuery,
+	}
+
 	QueryVPCEndpoint = &cobra.Command{
 		Use:     "endpoint",
 		Aliases: []string{"endpoints", "vpc-endpoint", "vpc-endpoints"},
@@ -80,9 +104,17 @@ func init() {
 	Query.AddCommand(QuerySubnet)
 	Query.AddCommand(QueryVPC)
 	Query.AddCommand(QueryVPCEndpoint)
+	Query.AddCommand(QueryAll)
+	Query.AddCommand(QueryExisting)
 }
 
 func runQuery(cmd *cobra.Command, _ []string) {
+	// query command itself can be run only with log group flag
+	if cmd.Name() == "query" && len(flag.Query.LogGroups()) == 0 {
+		_ = cmd.Help()
+		return
+	}
+
 	var flowLogType aws.FlowLogType
 	switch cmd.Name() {
 	case "instance":
@@ -97,69 +129,641 @@ func runQuery(cmd *cobra.Command, _ []string) {
 		flowLogType = aws.FlowLogTypeVPC
 	case "endpoint":
 		flowLogType = aws.FlowLogTypeVPCEndpoint
+	case "all":
+		flowLogType = aws.FlowLogTypeAll
 	}
 
+	q, err := flag.Query.GetQuery()
+	if err != nil {
+		fmt.Printf("query flags: %v\n", err)
+		os.Exit(1)
+	}
+	format := flag.Query.OutputFormat()
+	optional := flag.Query.Columns()
+
 	logger := flag.Global.Logger()
-	client := aws.NewClient(logger, flag.Global.AWSConfig())
+	client := aws.NewClient(logger, flag.Global.AWSConfig()).WithMaxScanBytes(flag.Query.MaxScanGB * logs.BytesPerGB)
+
+	selectedFlowLogs := selectQueryFlowLogs(cmd.Context(), client, cmd.Name(), flowLogType)
+	// label results by log group, if there is more than one
+	if names, _ := selectedFlowLogs.GetByLogGroupNames(); len(names) > 1 && !slices.Contains(optional, "log-group") {
+		optional = append([]string{"log-group"}, optional...)
+	}
+	ranges := loadIPRanges(optional)
+	if flag.Query.Follow {
+		followQuery(cmd.Context(), logger, client, format, selectedFlowLogs, q, ranges, optional)
+		return
+	}
+
+	ctx, cancel := flag.Query.TimeoutContext(cmd.Context())
+	defer cancel()
+
+	aggregation, isAggregation := q.GetAggregation()
+	columns := queryColumns(ranges, optional)
+	if flag.Query.Pretty && !isAggregation {
+		interfaces, err := client.ListNetworkInterfaces(ctx)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+		columns = prettyQueryColumns(interfaces, ranges, optional)
+	}
+	if flag.Query.MergeFlows {
+		columns = mergedFlowColumns(columns)
+	}
 
-	selectedFlowLogs := prompt.SelectFlowLogs(prompt.ListFlowLogs(client, flowLogType), false)
-	logs, err := client.QueryFlowLogs(selectedFlowLogs, flag.Query.GetQuery())
+	// partial rows are previewed only if the final results are rows with the same columns - not aggregation, raw
+	// pipeline (columns are not known), conversations or client side filtered and merged results
+	streamRows := !isAggregation && !q.IsRaw() && !flag.Query.Conversations && !flag.Query.IsClientSide()
+	stream := newQueryStream(logger, format, rowColumns(format, columns), streamRows)
+	results, queryStats, err := client.QueryFlowLogs(ctx, selectedFlowLogs, q, stream.update)
+	stream.clear()
+	stats := newQueryStatistics(queryStats, flag.Query.PricePerGB)
 	if err != nil {
-		fmt.Printf("query flow logs: %v\n", err)
+		if stats.BytesScanned > 0 {
+			fmt.Fprintln(os.Stderr, stats)
+		}
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			fmt.Printf("query flow logs: timed out after %s\n", flag.Query.Timeout)
+		case errors.Is(err, context.Canceled):
+			fmt.Println("query flow logs: interrupted")
+		case errors.Is(err, logs.ErrScanBudgetExceeded):
+			fmt.Printf("query flow logs: %v (see --max-scan-gb flag)\n", err)
+		default:
+			fmt.Printf("query flow logs: %v\n", err)
+		}
+		os.Exit(1)
+	}
+
+	if isAggregation {
+		printAggregation(logger, format, results, aggregation, stats)
+		printStatistics(format, stats)
+		return
+	}
+	if flag.Query.Conversations {
+		printConversations(logger, format, results, stats)
+		printStatistics(format, stats)
+		return
+	}
+	// raw pipeline can return any fields, flow logs view needs at least interface and addresses
+	if q.IsRaw() && !hasFlowFields(results) {
+		printGeneric(logger, format, results, stats)
+		printStatistics(format, stats)
+		return
+	}
+	if flag.Query.IsClientSide() {
+		results = clientSideResults(ranges, results)
+	}
+	stream.finish(results, stats)
+	printStatistics(format, stats)
+}
+
+// clientSideResults filters records by remote service and region and merges records of the same flow in consecutive
+// aggregation windows, limit flag is applied to the filtered or merged results
+func clientSideResults(ranges ipranges.Ranges, results []map[string]string) []map[string]string {
+	results = filterRemote(ranges, results)
+	if flag.Query.MergeFlows {
+		results = query.MergeFlows(results)
+	}
+	if limit := flag.Query.ResultLimit(); limit > 0 && len(results) > limit {
+		return results[:limit]
+	}
+	return results
+}
+
+// queryStatistics is Logs Insights query statistics with cost estimate, it is included in json output
+type queryStatistics struct {
+	RecordsScanned float64 `json:"recordsScanned"`
+	BytesScanned   float64 `json:"bytesScanned"`
+	RecordsMatched float64 `json:"recordsMatched"`
+	EstimatedCost  float64 `json:"estimatedCost"`
+}
+
+func newQueryStatistics(in logs.QueryStatistics, pricePerGB float64) queryStatistics {
+	return queryStatistics{
+		RecordsScanned: in.RecordsScanned,
+		BytesScanned:   in.BytesScanned,
+		RecordsMatched: in.RecordsMatched,
+		EstimatedCost:  in.BytesScanned / logs.BytesPerGB * pricePerGB,
+	}
+}
+
+func (s queryStatistics) String() string {
+	return fmt.Sprintf("scanned %s records (%s), matched %s records, estimated cost $%.4f",
+		formatCount(s.RecordsScanned), formatBytes(s.BytesScanned), formatCount(s.RecordsMatched), s.EstimatedCost)
+}
+
+// printStatistics prints statistics to stderr, so they are not mixed with the results, json output already contains them
+func printStatistics(format out.Format, stats queryStatistics) {
+	if format == out.FormatJSON {
+		return
+	}
+	fmt.Fprintln(os.Stderr, stats)
+}
+
+// selectQueryFlowLogs returns flow logs for log group flag, all flow logs created by cli for 'all' type, otherwise user
+// selects flow log groups, or log groups of any CloudWatch flow logs for 'existing' command
+func selectQueryFlowLogs(ctx context.Context, client aws.Client, cmdName string, flowLogType aws.FlowLogType) ec2.FlowLogs {
+	if logGroups := flag.Query.LogGroups(); len(logGroups) > 0 {
+		flowLogs, err := client.ListLogGroupFlowLogs(ctx, logGroups)
+		if err != nil {
+			fmt.Printf("list log group flow logs: %v\n", err)
+			os.Exit(1)
+		}
+		return flowLogs
+	}
+	if cmdName == "existing" {
+		flowLogs, err := client.ListCloudWatchFlowLogs(ctx)
+		if err != nil {
+			fmt.Printf("list flow logs: %v\n", err)
+			os.Exit(1)
+		}
+		return prompt.SelectLogGroupFlowLogs(flowLogs)
+	}
+
+	flowLogs := prompt.ListFlowLogs(ctx, client, flowLogType)
+	if flowLogType != aws.FlowLogTypeAll {
+		return prompt.SelectFlowLogGroups(flowLogs, false)
+	}
+
+	if len(flowLogs) == 0 {
+		fmt.Println("no flow logs found")
 		os.Exit(1)
 	}
+	names, _ := flowLogs.GetByNames()
+	fmt.Printf("%d flow logs found in %d groups\n", len(flowLogs), len(names))
+	return flowLogs
+}
 
+// followQuery prints flow logs continuously as they arrive, until interrupted
+func followQuery(ctx context.Context, logger *slog.Logger, client aws.Client, format out.Format, flowLogs ec2.FlowLogs, q query.Query, ranges ipranges.Ranges, optional []string) {
+	columns := queryColumns(ranges, optional)
 	if flag.Query.Pretty {
-		interfaces, err := client.ListNetworkInterfaces()
+		interfaces, err := client.ListNetworkInterfaces(ctx)
 		if err != nil {
 			fmt.Println(err.Error())
 			os.Exit(1)
 		}
-		prettyPrintQuery(logger, logs, interfaces)
-		return
+		columns = prettyQueryColumns(interfaces, ranges, optional)
+	}
+
+	p := newPrinter(logger, format, rowColumns(format, columns))
+	err := client.TailFlowLogs(ctx, flowLogs, q, func(rows []map[string]string) {
+		// live tail returns events in batches, print rows sorted by time
+		slices.SortStableFunc(rows, func(a, b map[string]string) int {
+			return strings.Compare(a["@timestamp"], b["@timestamp"])
+		})
+		p.addRows(rows)
+		p.print()
+	})
+	if err != nil {
+		fmt.Printf("follow flow logs: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// column is single query output column, name is used as table header and key as json/csv key. Key is empty, if the
+// column only formats raw field, raw fields are always included in machine-readable output.
+type column struct {
+	name  string
+	key   string
+	value func(row map[string]string) string
+}
+
+// printer writes header and rows of columns in the requested format
+type printer struct {
+	renderer out.Renderer
+	columns  []column
+}
+
+func newPrinter(logger *slog.Logger, format out.Format, columns []column) printer {
+	return newPrinterTo(logger, os.Stdout, format, columns)
+}
+
+// newPrinterTo returns printer that writes to the output instead of stdout
+func newPrinterTo(logger *slog.Logger, output io.Writer, format out.Format, columns []column) printer {
+	renderer := out.NewRenderer(logger, output, format)
+	var headers []string
+	for _, c := range columns {
+		if format.IsMachineReadable() {
+			headers = append(headers, c.key)
+			continue
+		}
+		headers = append(headers, c.name)
+	}
+	renderer.AddRow(headers...)
+	return printer{renderer: renderer, columns: columns}
+}
+
+func (p printer) addRows(rows []map[string]string) {
+	for _, row := range rows {
+		var values []string
+		for _, c := range p.columns {
+			values = append(values, c.value(row))
+		}
+		p.renderer.AddRow(values...)
+	}
+}
+
+// setStatistics adds query statistics to the output, only json output contains statistics
+func (p printer) setStatistics(stats queryStatistics) {
+	if j, ok := p.renderer.(*out.JSON); ok {
+		j.Set("statistics", stats)
+	}
+}
+
+func (p printer) print() {
+	p.renderer.Print()
+}
+
+// previewRows is the maximum number of partial result rows shown while the query is running, so the preview fits on
+// the screen and can be replaced by the final results
+const previewRows = 20
+
+// queryStream shows query progress (statistics) on stderr and preview of partial query results (see newQueryStream) on
+// stdout while the query is running. Rows are de-duplicated by @ptr (see logs.QueryProgress), newest rows are shown
+// first. Preview is replaced by the final sorted results when the query completes.
+type queryStream struct {
+	logger   *slog.Logger
+	format   out.Format
+	columns  []column
+	rows     bool
+	partial  []map[string]string
+	preview  *out.Preview
+	progress *out.Progress
+}
+
+// newQueryStream returns stream that shows partial rows if rows is true, otherwise it only shows progress. Rows should
+// be shown only if the final results have the same columns (query is not aggregated). Partial rows are shown only if
+// stdout is terminal and not in json format, because json document is printed only once.
+func newQueryStream(logger *slog.Logger, format out.Format, columns []column, rows bool) *queryStream {
+	return &queryStream{
+		logger:   logger,
+		format:   format,
+		columns:  columns,
+		rows:     rows && format != out.FormatJSON,
+		preview:  out.NewPreview(os.Stdout),
+		progress: out.NewProgress(os.Stderr),
+	}
+}
+
+func (s *queryStream) update(rows []map[string]string, stats logs.QueryStatistics) {
+	if s.rows && s.preview.IsTerminal() && len(rows) > 0 {
+		s.partial = append(s.partial, rows...)
+		// partial results are sorted per poll only, keep the newest rows first across polls
+		slices.SortStableFunc(s.partial, func(a, b map[string]string) int {
+			return strings.Compare(b["@timestamp"], a["@timestamp"])
+		})
+		var b strings.Builder
+		p := newPrinterTo(s.logger, &b, s.format, s.columns)
+		p.addRows(s.partial[:min(len(s.partial), previewRows)])
+		p.print()
+		s.progress.Clear()
+		s.preview.Update(b.String())
+	}
+	s.progress.Update(newQueryStatistics(stats, flag.Query.PricePerGB).String())
+}
+
+// clear removes progress line and preview of partial rows
+func (s *queryStream) clear() {
+	s.progress.Clear()
+	s.preview.Clear()
+}
+
+// finish prints complete query results
+func (s *queryStream) finish(rows []map[string]string, stats queryStatistics) {
+	p := newPrinter(s.logger, s.format, s.columns)
+	p.addRows(rows)
+	p.setStatistics(stats)
+	p.print()
+}
+
+// formatCount formats number with thousands separators
+func formatCount(in float64) string {
+	digits := strconv.FormatInt(int64(in), 10)
+	var out []byte
+	for i := range len(digits) {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out = append(out, ',')
+		}
+		out = append(out, digits[i])
+	}
+	return string(out)
+}
+
+// formatBytes formats bytes in human-readable units (B, KB, MB, GB, TB), units are powers of 1000
+func formatBytes(in float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	var i int
+	for in >= 1000 && i < len(units)-1 {
+		in /= 1000
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d %s", int64(in), units[i])
+	}
+	return fmt.Sprintf("%.1f %s", in, units[i])
+}
+
+func queryColumns(ranges ipranges.Ranges, optional []string) []column {
+	columns := []column{
+		{name: "TIME", value: func(row map[string]string) string { return query.ToTime(row["@timestamp"]) }},
+		{name: "NI ID", value: func(row map[string]string) string { return row["interfaceId"] }},
+	}
+	columns = append(columns, flowColumns()...)
+	return append(columns, optionalColumns(ranges, optional)...)
+}
+
+func prettyQueryColumns(interfaces ec2.NetworkInterfaces, ranges ipranges.Ranges, optional []string) []column {
+	columns := []column{
+		{name: "TIME", value: func(row map[string]string) string { return query.ToTime(row["@timestamp"]) }},
+		{name: "NI ID", value: func(row map[string]string) string { return row["interfaceId"] }},
+		{name: "TYPE", key: "niType", value: func(row map[string]string) string {
+			ni := interfaces.GetById(row["interfaceId"])
+			// most likely we are never going to see trunk, but just in case
+			if ni.InterfaceType == "branch" || ni.InterfaceType == "trunk" {
+				return fmt.Sprintf("%s (%s)", ni.Type, ni.InterfaceType)
+			}
+			return ni.Type
+		}},
+		{name: "NAME", key: "niName", value: func(row map[string]string) string {
+			if ecsSvc := row["ecsServiceName"]; ecsSvc != "" {
+				return ecsSvc
+			}
+			return interfaces.GetById(row["interfaceId"]).Name
+		}},
+	}
+	columns = append(columns, flowColumns()...)
+	return append(columns, optionalColumns(ranges, optional)...)
+}
+
+// mergedFlowColumns replaces time column (first column) with first seen, last seen, duration and number of merged
+// records columns
+func mergedFlowColumns(columns []column) []column {
+	merged := []column{
+		{name: "FIRST SEEN", value: func(row map[string]string) string { return query.UnixToTime(row["start"]) }},
+		{name: "LAST SEEN", value: func(row map[string]string) string { return query.UnixToTime(row["end"]) }},
+		{name: "DURATION", key: "duration", value: func(row map[string]string) string {
+			start, end := query.ToInt64(row["start"]), query.ToInt64(row["end"])
+			if start == 0 || end < start {
+				return ""
+			}
+			return (time.Duration(end-start) * time.Second).String()
+		}},
+		{name: "RECORDS", key: query.RecordsField, value: func(row map[string]string) string { return row[query.RecordsField] }},
+	}
+	return append(merged, columns[1:]...)
+}
+
+// flowColumns returns columns shared by both, pretty and standard output
+func flowColumns() []column {
+	return []column{
+		{name: "NI ADDRESS", key: "niAddr", value: func(row map[string]string) string { return ToFlow(row).NiAddr }},
+		{name: "NI PORT", key: "niPort", value: func(row map[string]string) string { return ToFlow(row).NiPort }},
+		{name: "FLOW", key: "flow", value: func(row map[string]string) string { return ToFlow(row).Flow }},
+		{name: "ADDRESS", key: "addr", value: func(row map[string]string) string { return ToFlow(row).Addr }},
+		{name: "PORT", key: "port", value: func(row map[string]string) string { return ToFlow(row).Port }},
+		{name: "ACTION", value: func(row map[string]string) string { return row["action"] }},
+		{name: "PACKETS", value: func(row map[string]string) string { return row["packets"] }},
+		{name: "BYTES", value: func(row map[string]string) string { return row["bytes"] }},
+		{name: "PROTOCOL", key: "protocolName", value: func(row map[string]string) string {
+			return query.ProtocolFromNumberToKeyword(row["protocol"])
+		}},
+		{name: "TCP FLAGS", key: "tcpFlagNames", value: func(row map[string]string) string {
+			return strings.Join(query.ToTcpFlagNames(row["tcpFlags"]), ", ")
+		}},
+		{name: "TRAFFIC PATH", key: "trafficPathName", value: func(row map[string]string) string {
+			return query.ToPathName(row["trafficPath"])
+		}},
+	}
+}
+
+// optionalColumnByKey are columns added to the output by columns flag, raw fields are always present in
+// machine-readable output, so optional columns have no key (except remote columns, see remoteColumnByKey)
+var optionalColumnByKey = map[string]column{
+	"log-group":   {name: "LOG GROUP", value: func(row map[string]string) string { return query.ToLogGroupName(row["@log"]) }},
+	"vpc-id":      {name: "VPC ID", value: func(row map[string]string) string { return row["vpcId"] }},
+	"subnet-id":   {name: "SUBNET ID", value: func(row map[string]string) string { return row["subnetId"] }},
+	"instance-id": {name: "INSTANCE ID", value: func(row map[string]string) string { return row["instanceId"] }},
+	"type":        {name: "TRAFFIC TYPE", value: func(row map[string]string) string { return row["type"] }},
+	"aws-service": {name: "AWS SERVICE", value: func(row map[string]string) string {
+		// service of the address column, the other side of the flow is the network interface
+		if row["flowDirection"] == "ingress" {
+			return row["pktSrcAwsService"]
+		}
+		return row["pktDstAwsService"]
+	}},
+	"start": {name: "START", value: func(row map[string]string) string { return query.UnixToTime(row["start"]) }},
+	"end":   {name: "END", value: func(row map[string]string) string { return query.UnixToTime(row["end"]) }},
+}
+
+func optionalColumns(ranges ipranges.Ranges, keys []string) []column {
+	var columns []column
+	for _, key := range keys {
+		c, ok := optionalColumnByKey[key]
+		if !ok {
+			c = remoteColumnByKey(ranges)[key]
+		}
+		columns = append(columns, c)
+	}
+	return columns
+}
+
+// rowColumns returns columns for the output format. Machine-readable formats get all raw query fields followed by
+// derived columns (columns with key), other formats get the columns unchanged
+func rowColumns(format out.Format, columns []column) []column {
+	if !format.IsMachineReadable() {
+		return columns
+	}
+
+	var machineColumns []column
+	for _, field := range query.Fields {
+		machineColumns = append(machineColumns, column{name: field, key: field, value: func(row map[string]string) string { return row[field] }})
+	}
+	for _, c := range columns {
+		if c.key != "" {
+			machineColumns = append(machineColumns, c)
+		}
 	}
-	printQuery(logger, logs)
+	return machineColumns
 }
 
-func printQuery(logger *slog.Logger, logs []map[string]string) {
-	table := out.NewTable(logger, os.Stdout)
-	table.AddRow("TIME", "NI ID", "NI ADDRESS", "NI PORT", "FLOW", "ADDRESS", "PORT", "ACTION", "PACKETS", "BYTES", "PROTOCOL", "TCP FLAGS", "TRAFFIC PATH")
+// hasFlowFields returns true if all results have fields required by the flow logs view
+func hasFlowFields(logs []map[string]string) bool {
+	if len(logs) == 0 {
+		return false
+	}
 	for _, row := range logs {
-		flow := ToFlow(row)
-		table.AddRow(
-			query.ToTime(row["@timestamp"]), row["interfaceId"], flow.NiAddr, flow.NiPort, flow.Flow, flow.Addr, flow.Port, row["action"],
-			row["packets"], row["bytes"], query.ProtocolFromNumberToKeyword(row["protocol"]),
-			strings.Join(query.ToTcpFlagNames(row["tcpFlags"]), ", "),
-			query.ToPathName(row["trafficPath"]),
-		)
+		for _, field := range []string{"interfaceId", "srcAddr", "dstAddr", "flowDirection"} {
+			if _, ok := row[field]; !ok {
+				return false
+			}
+		}
 	}
-	table.Print()
+	return true
 }
 
-func prettyPrintQuery(logger *slog.Logger, logs []map[string]string, interfaces ec2.NetworkInterfaces) {
-	table := out.NewTable(logger, os.Stdout)
-	table.AddRow("TIME", "NI ID", "TYPE", "NAME", "NI ADDRESS", "NI PORT", "FLOW", "ADDRESS", "PORT", "ACTION", "PACKETS", "BYTES", "PROTOCOL", "TCP FLAGS", "TRAFFIC PATH")
+// printGeneric prints results with a column per returned field, @ fields (e.g. @timestamp) first
+func printGeneric(logger *slog.Logger, format out.Format, logs []map[string]string, stats queryStatistics) {
+	var fields []string
 	for _, row := range logs {
-		flow := ToFlow(row)
-		ni := interfaces.GetById(row["interfaceId"])
-		name := ni.Name
-		if ecsSvc := row["ecsServiceName"]; ecsSvc != "" {
-			name = ecsSvc
+		for field := range row {
+			// pointer to the log event is returned with every result
+			if field != "@ptr" && !slices.Contains(fields, field) {
+				fields = append(fields, field)
+			}
+		}
+	}
+	slices.SortFunc(fields, func(a, b string) int {
+		if aRaw, bRaw := strings.HasPrefix(a, "@"), strings.HasPrefix(b, "@"); aRaw != bRaw {
+			if aRaw {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+
+	var columns []column
+	for _, field := range fields {
+		columns = append(columns, column{name: field, key: field, value: func(row map[string]string) string { return row[field] }})
+	}
+	p := newPrinter(logger, format, columns)
+	p.addRows(logs)
+	p.setStatistics(stats)
+	p.print()
+}
+
+var aggregationHeaderByField = map[string]string{
+	"srcAddr":       "SRC ADDRESS",
+	"dstAddr":       "DST ADDRESS",
+	"srcPort":       "SRC PORT",
+	"dstPort":       "DST PORT",
+	"interfaceId":   "NI ID",
+	"protocol":      "PROTOCOL",
+	"action":        "ACTION",
+	"flowDirection": "DIRECTION",
+	"@log":          "LOG GROUP",
+}
+
+// printAggregation prints grouped results with totals row (totals are omitted in machine-readable formats)
+func printAggregation(logger *slog.Logger, format out.Format, logs []map[string]string, aggregation query.Aggregation, stats queryStatistics) {
+	if aggregation.IsHistogram() {
+		printHistogram(logger, format, logs, aggregation, stats)
+		return
+	}
+
+	p := newPrinter(logger, format, aggregationColumns(aggregation))
+	p.addRows(logs)
+	// no need for totals if there is no grouping, the only row is total
+	if len(aggregation.GroupBy) > 0 && !format.IsMachineReadable() {
+		p.renderer.AddRow(aggregationTotals(logs, len(aggregation.GroupBy))...)
+	}
+	p.setStatistics(stats)
+	p.print()
+}
+
+// printHistogram prints time buckets with bar chart of the aggregation metric, machine-readable formats get numbers only
+func printHistogram(logger *slog.Logger, format out.Format, logs []map[string]string, aggregation query.Aggregation, stats queryStatistics) {
+	logs = aggregation.FillBins(logs)
+	binField := aggregation.BinField()
+
+	// date is displayed only if buckets span more than one day
+	timeLayout := "15:04:05"
+	var days []string
+	for _, row := range logs {
+		if t, err := time.Parse(query.InsightsTimeLayout, row[binField]); err == nil && !slices.Contains(days, t.Format(time.DateOnly)) {
+			days = append(days, t.Format(time.DateOnly))
+		}
+	}
+	if len(days) > 1 {
+		timeLayout = "2006-01-02 15:04"
+	}
+	columns := []column{{name: "TIME", key: "time", value: func(row map[string]string) string {
+		if format.IsMachineReadable() {
+			return row[binField]
+		}
+		t, err := time.Parse(query.InsightsTimeLayout, row[binField])
+		if err != nil {
+			return row[binField]
 		}
-		niType := ni.Type
-		// most likely we are never going to see trunk, but just in case
-		if ni.InterfaceType == "branch" || ni.InterfaceType == "trunk" {
-			niType = fmt.Sprintf("%s (%s)", ni.Type, ni.InterfaceType)
+		return t.Format(timeLayout)
+	}}}
+	columns = append(columns, aggregationColumns(aggregation)...)
+	if !format.IsMachineReadable() {
+		var maxValue int64
+		for _, row := range logs {
+			maxValue = max(maxValue, query.ToInt64(row[aggregation.SortBy]))
 		}
+		columns = append(columns, column{name: histogramHeaderByField[aggregation.SortBy], value: func(row map[string]string) string {
+			return histogramBar(query.ToInt64(row[aggregation.SortBy]), maxValue)
+		}})
+	}
+
+	p := newPrinter(logger, format, columns)
+	p.addRows(logs)
+	if !format.IsMachineReadable() {
+		p.renderer.AddRow(append(aggregationTotals(logs, len(aggregation.GroupBy)+1), "")...)
+	}
+	p.setStatistics(stats)
+	p.print()
+}
+
+var histogramHeaderByField = map[string]string{
+	query.RecordsField: "RECORDS CHART",
+	query.PacketsField: "PACKETS CHART",
+	query.BytesField:   "BYTES CHART",
+}
+
+// histogramBarWidth is width of the largest histogram bar in characters
+const histogramBarWidth = 40
+
+var barEighths = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
 
-		table.AddRow(
-			query.ToTime(row["@timestamp"]), row["interfaceId"], niType, name, flow.NiAddr, flow.NiPort, flow.Flow, flow.Addr, flow.Port, row["action"],
-			row["packets"], row["bytes"], query.ProtocolFromNumberToKeyword(row["protocol"]),
-			strings.Join(query.ToTcpFlagNames(row["tcpFlags"]), ", "),
-			query.ToPathName(row["trafficPath"]),
-		)
+// histogramBar returns bar of the value relative to the max value, bar has 1/8 character precision and non-zero
+// values are always visible
+func histogramBar(value, maxValue int64) string {
+	if value <= 0 || maxValue <= 0 {
+		return ""
 	}
-	table.Print()
+	eighths := max(value*histogramBarWidth*8/maxValue, 1)
+	return strings.Repeat("█", int(eighths/8)) + barEighths[eighths%8]
+}
+
+// aggregationColumns returns group by columns followed by records, packets and bytes columns
+func aggregationColumns(aggregation query.Aggregation) []column {
+	var columns []column
+	for _, field := range aggregation.GroupBy {
+		value := func(row map[string]string) string { return row[field] }
+		switch field {
+		case "protocol":
+			value = func(row map[string]string) string { return query.ProtocolFromNumberToKeyword(row[field]) }
+		case "@log":
+			value = func(row map[string]string) string { return query.ToLogGroupName(row[field]) }
+		}
+		columns = append(columns, column{name: aggregationHeaderByField[field], key: field, value: value})
+	}
+	columns = append(columns,
+		column{name: "RECORDS", key: query.RecordsField, value: func(row map[string]string) string { return row[query.RecordsField] }},
+		column{name: "PACKETS", key: query.PacketsField, value: func(row map[string]string) string { return row[query.PacketsField] }},
+		column{name: "BYTES", key: query.BytesField, value: func(row map[string]string) string { return row[query.BytesField] }},
+	)
+	return columns
+}
+
+// aggregationTotals returns totals row, labels is number of columns before records, packets and bytes columns
+func aggregationTotals(logs []map[string]string, labels int) []string {
+	var records, packets, bytes int64
+	for _, row := range logs {
+		records += query.ToInt64(row[query.RecordsField])
+		packets += query.ToInt64(row[query.PacketsField])
+		bytes += query.ToInt64(row[query.BytesField])
+	}
+
+	totals := make([]string, labels)
+	totals[0] = "TOTAL"
+	return append(totals, strconv.FormatInt(records, 10), strconv.FormatInt(packets, 10), strconv.FormatInt(bytes, 10))
 }
 
 type Flow struct {
@@ -205,5 +809,16 @@ func ToFlow(in map[string]string) Flow {
 			Port:   in["dstPort"],
 		}
 	}
+	// flow logs without flow-direction field (not created by cli) and unknown network interface, keep source on the
+	// left and destination on the right side
+	if in["srcAddr"] != "" {
+		return Flow{
+			Flow:   "---?----->",
+			NiAddr: in["srcAddr"],
+			NiPort: in["srcPort"],
+			Addr:   in["dstAddr"],
+			Port:   in["dstPort"],
+		}
+	}
 	return Flow{}
 }
@@ -0,0 +1,47 @@
+package cmd
+
+import "testing"
+
+func TestFormatCount(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{123456, "123,456"},
+		{1234567.8, "1,234,567"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.want, func(t *testing.T) {
+			if got := formatCount(tc.in); got != tc.want {
+				t.Errorf("formatCount(%v) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		in   float64
+		want string
+	}{
+		{0, "0 B"},
+		{999, "999 B"},
+		{1000, "1.0 KB"},
+		{1550000, "1.6 MB"},
+		{2.5e9, "2.5 GB"},
+		{3e12, "3.0 TB"},
+		{4e15, "4000.0 TB"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.want, func(t *testing.T) {
+			if got := formatBytes(tc.in); got != tc.want {
+				t.Errorf("formatBytes(%v) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/pete911/flowlogs/cmd/flag"
+	"github.com/pete911/flowlogs/internal/ipranges"
+	"github.com/spf13/cobra"
+)
+
+var UpdateRanges = &cobra.Command{
+	Use:   "update-ranges",
+	Short: "download AWS ip-ranges.json to the user cache directory, used by remote-service and remote-region columns and flags",
+	Long:  "",
+	Args:  cobra.NoArgs,
+	Run:   runUpdateRanges,
+}
+
+func init() {
+	Root.AddCommand(UpdateRanges)
+}
+
+func runUpdateRanges(cmd *cobra.Command, _ []string) {
+	path, err := ipranges.CachePath()
+	if err != nil {
+		fmt.Printf("update ip ranges: %v\n", err)
+		os.Exit(1)
+	}
+	ranges, err := ipranges.Update(cmd.Context(), ipranges.URL, path)
+	if err != nil {
+		fmt.Printf("update ip ranges: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("ip ranges created %s (%d prefixes) saved to %s\n", ranges.CreateDate, ranges.Len(), path)
+}
+
+// loadIPRanges loads AWS ip ranges if remote columns or filters are requested, empty ranges are returned otherwise
+func loadIPRanges(optional []string) ipranges.Ranges {
+	if flag.Query.RemoteFilter().IsEmpty() && !slices.Contains(optional, "remote-service") && !slices.Contains(optional, "remote-region") {
+		return ipranges.Ranges{}
+	}
+	path, err := flag.Query.IPRangesPath()
+	if err != nil {
+		fmt.Printf("ip ranges: %v\n", err)
+		os.Exit(1)
+	}
+	ranges, err := ipranges.Load(path)
+	if err != nil {
+		fmt.Printf("ip ranges: %v\n", err)
+		os.Exit(1)
+	}
+	return ranges
+}
+
+// remoteColumnByKey returns optional columns with AWS service and region of the address column (remote address)
+func remoteColumnByKey(ranges ipranges.Ranges) map[string]column {
+	return map[string]column{
+		"remote-service": {name: "REMOTE SERVICE", key: "remoteService", value: func(row map[string]string) string {
+			r, _ := ranges.Lookup(ToFlow(row).Addr)
+			return r.Service()
+		}},
+		"remote-region": {name: "REMOTE REGION", key: "remoteRegion", value: func(row map[string]string) string {
+			r, _ := ranges.Lookup(ToFlow(row).Addr)
+			return r.Region
+		}},
+	}
+}
+
+// filterRemote returns records with remote address (address column) in ip ranges of remote service and region flags
+func filterRemote(ranges ipranges.Ranges, rows []map[string]string) []map[string]string {
+	filter := flag.Query.RemoteFilter()
+	if filter.IsEmpty() {
+		return rows
+	}
+	var out []map[string]string
+	for _, row := range rows {
+		if ranges.Match(ToFlow(row).Addr, filter) {
+			out = append(out, row)
+		}
+	}
+	return out
+}
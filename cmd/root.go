@@ -1,12 +1,17 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/pete911/flowlogs/cmd/flag"
 	"github.com/spf13/cobra"
 )
 
 var (
-	Root = &cobra.Command{}
+	Root = &cobra.Command{
+		PersistentPreRun: applyConfig,
+	}
 
 	Version string
 )
@@ -14,3 +19,17 @@ var (
 func init() {
 	flag.InitPersistentFlags(Root, &flag.Global)
 }
+
+// applyConfig sets flags from the config file (query presets and defaults) before any command runs. Invalid config
+// file fails only commands with query flags, other commands (version, delete, ...) log warning and continue
+func applyConfig(cmd *cobra.Command, _ []string) {
+	err := flag.ApplyConfig(cmd)
+	if err == nil {
+		return
+	}
+	if flag.HasQueryFlags(cmd) {
+		fmt.Printf("config: %v\n", err)
+		os.Exit(1)
+	}
+	flag.Global.Logger().Warn("config file not applied", "error", err)
+}
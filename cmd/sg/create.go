@@ -20,13 +20,14 @@ var (
 	}
 )
 
-func runCreate(_ *cobra.Command, _ []string) {
+func runCreate(cmd *cobra.Command, _ []string) {
+	ctx := cmd.Context()
 	logger := flag.Global.Logger()
 	client := aws.NewClient(logger, flag.Global.AWSConfig())
 
-	selectedVPC := prompt.SelectVPC(prompt.ListVPCs(client), false)
-	selectedSecurityGroup := prompt.SelectSecurityGroup(prompt.ListSecurityGroups(client, selectedVPC.Id), true)
-	logGroup, err := client.CreateSecurityGroupFlowLogs(selectedSecurityGroup)
+	selectedVPC := prompt.SelectVPC(prompt.ListVPCs(ctx, client), false)
+	selectedSecurityGroup := prompt.SelectSecurityGroup(prompt.ListSecurityGroups(ctx, client, selectedVPC.Id), true)
+	logGroup, err := client.CreateSecurityGroupFlowLogs(ctx, selectedSecurityGroup)
 	if err != nil {
 		fmt.Printf("create flow logs: %v\n", err)
 		os.Exit(1)
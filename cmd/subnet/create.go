@@ -20,13 +20,14 @@ var (
 	}
 )
 
-func runCreate(_ *cobra.Command, _ []string) {
+func runCreate(cmd *cobra.Command, _ []string) {
+	ctx := cmd.Context()
 	logger := flag.Global.Logger()
 	client := aws.NewClient(logger, flag.Global.AWSConfig())
 
-	selectedVPC := prompt.SelectVPC(prompt.ListVPCs(client), false)
-	selectedSubnet := prompt.SelectSubnet(prompt.ListSubnets(client, selectedVPC.Id), true)
-	logGroup, err := client.CreateSubnetFlowLogs(selectedSubnet)
+	selectedVPC := prompt.SelectVPC(prompt.ListVPCs(ctx, client), false)
+	selectedSubnet := prompt.SelectSubnet(prompt.ListSubnets(ctx, client, selectedVPC.Id), true)
+	logGroup, err := client.CreateSubnetFlowLogs(ctx, selectedSubnet)
 	if err != nil {
 		fmt.Printf("create flow logs: %v\n", err)
 		os.Exit(1)
@@ -21,12 +21,13 @@ var (
 	}
 )
 
-func runDelete(_ *cobra.Command, _ []string) {
+func runDelete(cmd *cobra.Command, _ []string) {
+	ctx := cmd.Context()
 	logger := flag.Global.Logger()
 	client := aws.NewClient(logger, flag.Global.AWSConfig())
 
-	selectedFlowLog := prompt.SelectFlowLog(prompt.ListFlowLogs(client, aws.FlowLogTypeSubnet), true)
-	if err := client.DeleteResources(ec2.FlowLogs{selectedFlowLog}); err != nil {
+	selectedFlowLog := prompt.SelectFlowLog(prompt.ListFlowLogs(ctx, client, aws.FlowLogTypeSubnet), true)
+	if err := client.DeleteResources(ctx, ec2.FlowLogs{selectedFlowLog}); err != nil {
 		fmt.Printf("delete flow logs: %v\n", err)
 		os.Exit(1)
 	}
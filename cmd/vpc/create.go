@@ -20,12 +20,13 @@ var (
 	}
 )
 
-func runCreate(_ *cobra.Command, _ []string) {
+func runCreate(cmd *cobra.Command, _ []string) {
+	ctx := cmd.Context()
 	logger := flag.Global.Logger()
 	client := aws.NewClient(logger, flag.Global.AWSConfig())
 
-	selectedVPC := prompt.SelectVPC(prompt.ListVPCs(client), true)
-	logGroup, err := client.CreateVPCFlowLogs(selectedVPC)
+	selectedVPC := prompt.SelectVPC(prompt.ListVPCs(ctx, client), true)
+	logGroup, err := client.CreateVPCFlowLogs(ctx, selectedVPC)
 	if err != nil {
 		fmt.Printf("create flow logs: %v\n", err)
 		os.Exit(1)
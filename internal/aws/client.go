@@ -1,9 +1,12 @@
 package aws
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 
 	"github.com/pete911/flowlogs/internal/aws/ec2"
 	"github.com/pete911/flowlogs/internal/aws/iam"
@@ -24,11 +27,12 @@ const (
 )
 
 type Client struct {
-	config     Config
-	logger     *slog.Logger
-	ec2client  ec2.Client
-	logsClient logs.Client
-	iamClient  iam.Client
+	config       Config
+	logger       *slog.Logger
+	ec2client    ec2.Client
+	logsClient   logs.Client
+	iamClient    iam.Client
+	maxScanBytes float64
 }
 
 func NewClient(logger *slog.Logger, cfg Config) Client {
@@ -41,109 +45,146 @@ func NewClient(logger *slog.Logger, cfg Config) Client {
 	}
 }
 
-func (c Client) ListFlowLogs(flowLogType FlowLogType) (ec2.FlowLogs, error) {
+// WithMaxScanBytes returns client that refuses to start queries that are expected to scan more than max bytes and stops
+// queries once all queries run by the client scan more than max bytes. Zero max bytes does not limit queries.
+func (c Client) WithMaxScanBytes(maxBytes float64) Client {
+	if maxBytes <= 0 {
+		return c
+	}
+	c.maxScanBytes = maxBytes
+	c.logsClient = c.logsClient.WithScanBudget(logs.NewScanBudget(maxBytes))
+	return c
+}
+
+// ListCloudWatchFlowLogs returns all flow logs in the region delivered to CloudWatch logs (not only created by cli)
+func (c Client) ListCloudWatchFlowLogs(ctx context.Context) (ec2.FlowLogs, error) {
+	return c.ec2client.ListCloudWatchFlowLogs(ctx)
+}
+
+// ListLogGroupFlowLogs returns flow logs delivered to the log groups. Log groups without flow log (e.g. flow log was
+// deleted, but the log group is kept) are returned as flow log with the default log format.
+func (c Client) ListLogGroupFlowLogs(ctx context.Context, logGroupNames []string) (ec2.FlowLogs, error) {
+	flowLogs, err := c.ec2client.ListCloudWatchFlowLogs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	_, flowLogsByLogGroup := flowLogs.GetByLogGroupNames()
+
+	var out ec2.FlowLogs
+	for _, name := range logGroupNames {
+		if v, ok := flowLogsByLogGroup[name]; ok {
+			out = append(out, v...)
+			continue
+		}
+		c.logger.Warn(fmt.Sprintf("no flow log found for log group %s, using default log format", name))
+		out = append(out, ec2.FlowLog{LogGroupName: name})
+	}
+	return out, nil
+}
+
+func (c Client) ListFlowLogs(ctx context.Context, flowLogType FlowLogType) (ec2.FlowLogs, error) {
 	// tag name does not matter, we are going to delete it and search by name prefix
 	tags := NewTags("")
 	delete(tags, "Name")
 	// we use vpc id, which has 'vpc-' prefix
-	return c.ec2client.ListFlowLogs(string(flowLogType), tags)
+	return c.ec2client.ListFlowLogs(ctx, string(flowLogType), tags)
 }
 
-func (c Client) ListVPCs() (ec2.VPCs, error) {
-	return c.ec2client.ListVPCs(c.config.Account)
+func (c Client) ListVPCs(ctx context.Context) (ec2.VPCs, error) {
+	return c.ec2client.ListVPCs(ctx, c.config.Account)
 }
 
-func (c Client) CreateVPCFlowLogs(vpc ec2.VPC) (string, error) {
+func (c Client) CreateVPCFlowLogs(ctx context.Context, vpc ec2.VPC) (string, error) {
 	tags := tagsFromId(vpc.Id)
-	logGroupName, roleArn, err := c.createLogGroupAndRole(vpc.Id, tags)
+	logGroupName, roleArn, err := c.createLogGroupAndRole(ctx, vpc.Id, tags)
 	if err != nil {
 		return "", err
 	}
 
-	if err := c.ec2client.CreateVPCFlowLogs(vpc, logGroupName, roleArn, tags); err != nil {
+	if err := c.ec2client.CreateVPCFlowLogs(ctx, vpc, logGroupName, roleArn, tags); err != nil {
 		return "", err
 	}
 	c.logger.Info("flow logs created")
 	return logGroupName, err
 }
 
-func (c Client) ListVPCEndpoints() (ec2.VPCEndpoints, error) {
-	return c.ec2client.ListVPCEndpoints()
+func (c Client) ListVPCEndpoints(ctx context.Context) (ec2.VPCEndpoints, error) {
+	return c.ec2client.ListVPCEndpoints(ctx)
 }
 
-func (c Client) CreateVPCEndpointFlowLogs(endpoint ec2.VPCEndpoint) (string, error) {
+func (c Client) CreateVPCEndpointFlowLogs(ctx context.Context, endpoint ec2.VPCEndpoint) (string, error) {
 	tags := tagsFromId(endpoint.VpcEndpointId)
-	logGroupName, roleArn, err := c.createLogGroupAndRole(endpoint.VpcEndpointId, tags)
+	logGroupName, roleArn, err := c.createLogGroupAndRole(ctx, endpoint.VpcEndpointId, tags)
 	if err != nil {
 		return "", err
 	}
 
-	if err := c.ec2client.CreateVPCEndpointFlowLogs(endpoint, logGroupName, roleArn, tags); err != nil {
+	if err := c.ec2client.CreateVPCEndpointFlowLogs(ctx, endpoint, logGroupName, roleArn, tags); err != nil {
 		return "", err
 	}
 	c.logger.Info("flow logs created")
 	return logGroupName, err
 }
 
-func (c Client) ListSubnets(vpcId string) (ec2.Subnets, error) {
-	return c.ec2client.ListSubnets(c.config.Account, vpcId)
+func (c Client) ListSubnets(ctx context.Context, vpcId string) (ec2.Subnets, error) {
+	return c.ec2client.ListSubnets(ctx, c.config.Account, vpcId)
 }
 
-func (c Client) CreateSubnetFlowLogs(subnet ec2.Subnet) (string, error) {
+func (c Client) CreateSubnetFlowLogs(ctx context.Context, subnet ec2.Subnet) (string, error) {
 	tags := tagsFromId(subnet.Id)
-	logGroupName, roleArn, err := c.createLogGroupAndRole(subnet.Id, tags)
+	logGroupName, roleArn, err := c.createLogGroupAndRole(ctx, subnet.Id, tags)
 	if err != nil {
 		return "", err
 	}
 
-	if err := c.ec2client.CreateSubnetFlowLogs(subnet, logGroupName, roleArn, tags); err != nil {
+	if err := c.ec2client.CreateSubnetFlowLogs(ctx, subnet, logGroupName, roleArn, tags); err != nil {
 		return "", err
 	}
 	c.logger.Info("flow logs created")
 	return logGroupName, err
 }
 
-func (c Client) ListSecurityGroups(vpcId string) (ec2.SecurityGroups, error) {
-	return c.ec2client.ListSecurityGroups(c.config.Account, vpcId)
+func (c Client) ListSecurityGroups(ctx context.Context, vpcId string) (ec2.SecurityGroups, error) {
+	return c.ec2client.ListSecurityGroups(ctx, c.config.Account, vpcId)
 }
 
-func (c Client) CreateSecurityGroupFlowLogs(securityGroup ec2.SecurityGroup) (string, error) {
+func (c Client) CreateSecurityGroupFlowLogs(ctx context.Context, securityGroup ec2.SecurityGroup) (string, error) {
 	tags := tagsFromId(securityGroup.Id)
-	logGroupName, roleArn, err := c.createLogGroupAndRole(securityGroup.Id, tags)
+	logGroupName, roleArn, err := c.createLogGroupAndRole(ctx, securityGroup.Id, tags)
 	if err != nil {
 		return "", err
 	}
 
-	if err := c.ec2client.CreateSecurityGroupFlowLogs(securityGroup, logGroupName, roleArn, tags); err != nil {
+	if err := c.ec2client.CreateSecurityGroupFlowLogs(ctx, securityGroup, logGroupName, roleArn, tags); err != nil {
 		return "", err
 	}
 	c.logger.Info("flow logs created")
 	return logGroupName, err
 }
 
-func (c Client) ListNatGateways(vpcId string) (ec2.NatGateways, error) {
-	return c.ec2client.ListNatGateways(vpcId)
+func (c Client) ListNatGateways(ctx context.Context, vpcId string) (ec2.NatGateways, error) {
+	return c.ec2client.ListNatGateways(ctx, vpcId)
 }
 
-func (c Client) CreateNatGatewayFlowLogs(natGateway ec2.NatGateway) (string, error) {
+func (c Client) CreateNatGatewayFlowLogs(ctx context.Context, natGateway ec2.NatGateway) (string, error) {
 	tags := tagsFromId(natGateway.Id)
-	logGroupName, roleArn, err := c.createLogGroupAndRole(natGateway.Id, tags)
+	logGroupName, roleArn, err := c.createLogGroupAndRole(ctx, natGateway.Id, tags)
 	if err != nil {
 		return "", err
 	}
 
-	if err := c.ec2client.CreateNatGatewayFlowLogs(natGateway, logGroupName, roleArn, tags); err != nil {
+	if err := c.ec2client.CreateNatGatewayFlowLogs(ctx, natGateway, logGroupName, roleArn, tags); err != nil {
 		return "", err
 	}
 	c.logger.Info("flow logs created")
 	return logGroupName, err
 }
 
-func (c Client) ListInstances(vpcId string) (ec2.Instances, error) {
-	return c.ec2client.ListInstances(vpcId)
+func (c Client) ListInstances(ctx context.Context, vpcId string) (ec2.Instances, error) {
+	return c.ec2client.ListInstances(ctx, vpcId)
 }
 
-func (c Client) CreateInstanceFlowLogs(instances ec2.Instances) (string, error) {
+func (c Client) CreateInstanceFlowLogs(ctx context.Context, instances ec2.Instances) (string, error) {
 	if err := validateInstances(instances); err != nil {
 		return "", err
 	}
@@ -151,12 +192,12 @@ func (c Client) CreateInstanceFlowLogs(instances ec2.Instances) (string, error)
 	// we don't use id only for instances, because they are grouped by name, instead we prefix name with 'instance-'
 	id := fmt.Sprintf("%s%s", FlowLogTypeInstance, instances[0].Name)
 	tags := tagsFromId(id)
-	logGroupName, roleArn, err := c.createLogGroupAndRole(id, tags)
+	logGroupName, roleArn, err := c.createLogGroupAndRole(ctx, id, tags)
 	if err != nil {
 		return "", err
 	}
 
-	if err := c.ec2client.CreateInstancesFlowLogs(instances, logGroupName, roleArn, tags); err != nil {
+	if err := c.ec2client.CreateInstancesFlowLogs(ctx, instances, logGroupName, roleArn, tags); err != nil {
 		return "", err
 	}
 	c.logger.Info("flow logs created")
@@ -164,15 +205,15 @@ func (c Client) CreateInstanceFlowLogs(instances ec2.Instances) (string, error)
 }
 
 // createLogGroupAndRole creates cloud watch log group and IAM role and returns log group and IAM role ARN
-func (c Client) createLogGroupAndRole(id string, tags map[string]string) (string, string, error) {
+func (c Client) createLogGroupAndRole(ctx context.Context, id string, tags map[string]string) (string, string, error) {
 	logGroupName := logGroupNameFromId(id)
-	if err := c.logsClient.CreateLogGroup(logGroupName, tags); err != nil {
+	if err := c.logsClient.CreateLogGroup(ctx, logGroupName, tags); err != nil {
 		return "", "", err
 	}
 	c.logger.Info(fmt.Sprintf("log group %s created", logGroupName))
 
 	roleName := c.iamRoleNameFromId(id)
-	roleArn, err := c.iamClient.CreateFlowLogsRole(roleName, tags)
+	roleArn, err := c.iamClient.CreateFlowLogsRole(ctx, roleName, tags)
 	if err != nil {
 		return "", "", err
 	}
@@ -180,57 +221,284 @@ func (c Client) createLogGroupAndRole(id string, tags map[string]string) (string
 	return logGroupName, roleArn, err
 }
 
-// QueryFlowLogs run query on specified flow logs
-func (c Client) QueryFlowLogs(flowLogs ec2.FlowLogs, query query.Query) ([]map[string]string, error) {
+// QueryFlowLogs run query on specified flow logs. Logs Insights query can search at most 50 log groups, if there are
+// more log groups, they are split to batches that are queried separately and results are merged. Progress (can be nil)
+// receives partial results while the query is running, partial results are not reported for batches and complete (all
+// results) queries, because their results are merged only after all queries complete. Statistics are sum of all
+// queries and are returned on error as well (scanned bytes are billed even if the query fails).
+func (c Client) QueryFlowLogs(ctx context.Context, flowLogs ec2.FlowLogs, query query.Query, progress logs.QueryProgress) ([]map[string]string, logs.QueryStatistics, error) {
 	if len(flowLogs) == 0 {
 		c.logger.Info("no flow logs provided, nothing to query")
-		return nil, nil
+		return nil, logs.QueryStatistics{}, nil
+	}
+	if err := query.Err(); err != nil {
+		return nil, logs.QueryStatistics{}, fmt.Errorf("invalid query: %w", err)
+	}
+
+	logGroupNames, flowLogsByLogGroup := flowLogs.GetByLogGroupNames()
+	if len(logGroupNames) > logs.MaxQueryLogGroups && !query.IsMergeable() {
+		return nil, logs.QueryStatistics{}, fmt.Errorf("insights pipeline with stats, sort or dedup cannot query more than %d log groups, results of log group batches cannot be merged", logs.MaxQueryLogGroups)
+	}
+	c.warnMissingFields(logGroupNames, flowLogsByLogGroup)
+	if err := c.checkScanEstimate(ctx, logGroupNames, query); err != nil {
+		return nil, logs.QueryStatistics{}, err
+	}
+
+	addFlowDirection := c.flowDirection(ctx, flowLogs)
+	if len(logGroupNames) <= logs.MaxQueryLogGroups {
+		out, stats, err := c.queryLogGroups(ctx, logGroupNames, query, withFlowDirection(progress, addFlowDirection))
+		if err != nil {
+			return nil, stats, err
+		}
+		addFlowDirection(out)
+		return out, stats, nil
+	}
+
+	var (
+		results [][]map[string]string
+		stats   logs.QueryStatistics
+	)
+	for batch := range slices.Chunk(logGroupNames, logs.MaxQueryLogGroups) {
+		c.logger.Info(fmt.Sprintf("querying batch of %d log groups", len(batch)))
+		out, batchStats, err := c.queryLogGroups(ctx, batch, query, statisticsOnly(progress))
+		stats = stats.Add(batchStats)
+		if err != nil {
+			return nil, stats, err
+		}
+		results = append(results, out)
+	}
+	out := mergeResults(query, results)
+	addFlowDirection(out)
+	return out, stats, nil
+}
+
+// checkScanEstimate returns error if the query is expected to scan more than max scan bytes. Estimate is based on log
+// group stored bytes and query time range.
+func (c Client) checkScanEstimate(ctx context.Context, logGroupNames []string, query query.Query) error {
+	if c.maxScanBytes <= 0 {
+		return nil
+	}
+
+	estimate, err := c.logsClient.EstimateScanBytes(ctx, logGroupNames, query.GetStart(), query.GetEnd())
+	if err != nil {
+		return fmt.Errorf("estimate scanned bytes: %w", err)
+	}
+	c.logger.Debug(fmt.Sprintf("query is expected to scan %.2f GB", estimate/logs.BytesPerGB))
+	if estimate > c.maxScanBytes {
+		return fmt.Errorf("query is expected to scan %.2f GB, more than %.2f GB: %w", estimate/logs.BytesPerGB, c.maxScanBytes/logs.BytesPerGB, logs.ErrScanBudgetExceeded)
+	}
+	return nil
+}
+
+// withFlowDirection returns progress that receives rows with flow direction set
+func withFlowDirection(progress logs.QueryProgress, addFlowDirection func(rows []map[string]string)) logs.QueryProgress {
+	if progress == nil {
+		return nil
+	}
+	return func(rows []map[string]string, stats logs.QueryStatistics) {
+		addFlowDirection(rows)
+		progress(rows, stats)
+	}
+}
+
+// statisticsOnly returns progress that receives only query statistics (rows are not passed)
+func statisticsOnly(progress logs.QueryProgress) logs.QueryProgress {
+	if progress == nil {
+		return nil
+	}
+	return func(_ []map[string]string, stats logs.QueryStatistics) {
+		progress(nil, stats)
+	}
+}
+
+// warnMissingFields logs query fields that are not in the log format of flow logs (flow logs not created by cli),
+// these fields are empty and filters on these fields do not match any flow logs. V7 (ECS) fields are optional.
+func (c Client) warnMissingFields(logGroupNames []string, flowLogsByLogGroup map[string]ec2.FlowLogs) {
+	for _, name := range logGroupNames {
+		fields := flowLogsByLogGroup[name][0].Fields()
+		var missing []string
+		for _, field := range query.Fields {
+			if strings.HasPrefix(field, "@") || query.FlowLogFieldsV7.Contains(field) {
+				continue
+			}
+			if !fields.Contains(field) {
+				missing = append(missing, field)
+			}
+		}
+		if len(missing) > 0 {
+			c.logger.Warn(fmt.Sprintf("log group %s flow logs do not have %s fields, filters on these fields do not match", name, strings.Join(missing, ", ")))
+		}
+	}
+}
+
+// flowDirection returns function that sets flow direction on results from flow logs that do not have flow-direction
+// field in the log format. Direction is derived from the network interface addresses, rows with unknown interface are
+// not changed.
+func (c Client) flowDirection(ctx context.Context, flowLogs ec2.FlowLogs) func(rows []map[string]string) {
+	noop := func([]map[string]string) {}
+	if !slices.ContainsFunc(flowLogs, func(f ec2.FlowLog) bool { return !f.Fields().Contains("flowDirection") }) {
+		return noop
+	}
+
+	interfaces, err := c.ec2client.ListNetworkInterfaces(ctx)
+	if err != nil {
+		c.logger.Warn(fmt.Sprintf("flow direction: list network interfaces: %v", err))
+		return noop
+	}
+	return func(rows []map[string]string) {
+		for _, row := range rows {
+			setFlowDirection(row, interfaces)
+		}
+	}
+}
+
+func setFlowDirection(row map[string]string, interfaces ec2.NetworkInterfaces) {
+	if v := row["flowDirection"]; v != "" && v != "-" {
+		return
 	}
 
-	var logGroupNames []string
-	for _, v := range flowLogs {
-		logGroupNames = append(logGroupNames, logGroupNameFromFlowLogName(v.Name))
+	ni := interfaces.GetById(row["interfaceId"])
+	switch {
+	case ni.HasIp(row["dstAddr"]):
+		row["flowDirection"] = "ingress"
+	case ni.HasIp(row["srcAddr"]):
+		row["flowDirection"] = "egress"
+	}
+}
+
+func (c Client) queryLogGroups(ctx context.Context, logGroupNames []string, query query.Query, progress logs.QueryProgress) ([]map[string]string, logs.QueryStatistics, error) {
+	if query.IsComplete() {
+		return c.logsClient.QueryAll(ctx, logGroupNames, query.GetQuery(), query.GetStart(), query.GetEnd())
+	}
+	return c.logsClient.Query(ctx, logGroupNames, query.GetQuery(), query.GetStart(), query.GetEnd(), query.GetLimit(), progress)
+}
+
+// mergeResults merges results of query batches, aggregations are summed, other results are sorted by timestamp (newest
+// first) and limited to the query limit, unless the query is complete
+func mergeResults(query query.Query, results [][]map[string]string) []map[string]string {
+	var out []map[string]string
+	if aggregation, ok := query.GetAggregation(); ok {
+		out = aggregation.Merge(results...)
+	} else {
+		out = slices.Concat(results...)
+		// timestamp format (2024-12-04 14:50:07.000) can be sorted as a string
+		slices.SortStableFunc(out, func(a, b map[string]string) int {
+			return strings.Compare(b["@timestamp"], a["@timestamp"])
+		})
+		if query.IsComplete() {
+			return out
+		}
+	}
+
+	if len(out) > query.GetLimit() {
+		return out[:query.GetLimit()]
+	}
+	return out
+}
+
+// TailFlowLogs streams new flow log records from specified flow logs, records are parsed (using the format the flow
+// logs are created with), filtered by query filters and passed to the handler
+func (c Client) TailFlowLogs(ctx context.Context, flowLogs ec2.FlowLogs, q query.Query, handler func(rows []map[string]string)) error {
+	if len(flowLogs) == 0 {
+		c.logger.Info("no flow logs provided, nothing to tail")
+		return nil
+	}
+	if err := q.Err(); err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+
+	logGroupNames, flowLogsByLogGroup := flowLogs.GetByLogGroupNames()
+	// live tail events are labelled by log group arn
+	fieldsByLogGroup := make(map[string][]query.FlowLogFields)
+	for _, name := range logGroupNames {
+		for _, flowLog := range flowLogsByLogGroup[name] {
+			fieldsByLogGroup[name] = append(fieldsByLogGroup[name], flowLog.Fields())
+		}
+	}
+
+	var interfaces ec2.NetworkInterfaces
+	if slices.ContainsFunc(flowLogs, func(f ec2.FlowLog) bool { return !f.Fields().Contains("flowDirection") }) {
+		var err error
+		if interfaces, err = c.ec2client.ListNetworkInterfaces(ctx); err != nil {
+			c.logger.Warn(fmt.Sprintf("flow direction: list network interfaces: %v", err))
+		}
+	}
+
+	return c.logsClient.Tail(ctx, logGroupNames, func(events []logs.LogEvent) {
+		var rows []map[string]string
+		for _, event := range events {
+			logGroupName := query.ToLogGroupName(event.LogGroup)
+			row, err := parseRecord(fieldsByLogGroup[logGroupName], event.Message)
+			if err != nil {
+				c.logger.Warn(fmt.Sprintf("log group %s: parse flow log record: %v", logGroupName, err))
+				continue
+			}
+			// same format as the Logs Insights @timestamp and @log fields
+			row["@timestamp"] = event.Timestamp.UTC().Format(query.InsightsTimeLayout)
+			row["@log"] = event.LogGroup
+			if interfaces != nil {
+				setFlowDirection(row, interfaces)
+			}
+			if q.Match(row) {
+				rows = append(rows, row)
+			}
+		}
+		if len(rows) > 0 {
+			handler(rows)
+		}
+	})
+}
+
+// parseRecord parses flow log record using log format that has the same number of fields as the record. Flow logs
+// in the same log group can have different formats, e.g. cli creates flow logs without V7 fields, if there is no ECS
+// cluster in the VPC
+func parseRecord(formats []query.FlowLogFields, record string) (map[string]string, error) {
+	values := len(strings.Fields(record))
+	for _, fields := range formats {
+		if len(fields) == values {
+			return fields.ParseRecord(record)
+		}
 	}
-	return c.logsClient.Query(logGroupNames, query.GetQuery(), query.GetSinceMinutes(), query.GetLimit())
+	return nil, fmt.Errorf("no log format with %d fields", values)
 }
 
-func (c Client) ListNetworkInterfaces() (ec2.NetworkInterfaces, error) {
-	return c.ec2client.ListNetworkInterfaces()
+func (c Client) ListNetworkInterfaces(ctx context.Context) (ec2.NetworkInterfaces, error) {
+	return c.ec2client.ListNetworkInterfaces(ctx)
 }
 
 // DeleteResources delete flow logs, IAM roles and cloud watch log groups
-func (c Client) DeleteResources(flowLogs ec2.FlowLogs) error {
+func (c Client) DeleteResources(ctx context.Context, flowLogs ec2.FlowLogs) error {
 	if len(flowLogs) == 0 {
 		c.logger.Info("no flow logs provided, nothing to delete")
 		return nil
 	}
 
-	if err := c.DeleteFlowLogs(flowLogs); err != nil {
+	if err := c.DeleteFlowLogs(ctx, flowLogs); err != nil {
 		return err
 	}
-	if err := c.DeleteIAMRoles(flowLogs); err != nil {
+	if err := c.DeleteIAMRoles(ctx, flowLogs); err != nil {
 		return err
 	}
-	if err := c.DeleteLogGroups(flowLogs); err != nil {
+	if err := c.DeleteLogGroups(ctx, flowLogs); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c Client) DeleteFlowLogs(flowLogs ec2.FlowLogs) error {
+func (c Client) DeleteFlowLogs(ctx context.Context, flowLogs ec2.FlowLogs) error {
 	if len(flowLogs) == 0 {
 		c.logger.Info("no flow logs provided, nothing to delete")
 		return nil
 	}
 
-	if err := c.ec2client.DeleteFlowLogs(flowLogs.Ids()); err != nil {
+	if err := c.ec2client.DeleteFlowLogs(ctx, flowLogs.Ids()); err != nil {
 		return fmt.Errorf("delete flow logs: %w", err)
 	}
 	c.logger.Info(fmt.Sprintf("%d flow logs deleted", len(flowLogs)))
 	return nil
 }
 
-func (c Client) DeleteIAMRoles(flowLogs ec2.FlowLogs) error {
+func (c Client) DeleteIAMRoles(ctx context.Context, flowLogs ec2.FlowLogs) error {
 	if len(flowLogs) == 0 {
 		c.logger.Info("no flow logs provided, nothing to delete")
 		return nil
@@ -246,7 +514,7 @@ func (c Client) DeleteIAMRoles(flowLogs ec2.FlowLogs) error {
 		tags := flowLogsByName[name][0].Tags()
 		delete(tags, "Name")
 		roleName := c.iamRoleNameFromFlowLogName(name)
-		if err := c.iamClient.DeleteRole(roleName, tags); err != nil {
+		if err := c.iamClient.DeleteRole(ctx, roleName, tags); err != nil {
 			return fmt.Errorf("delete iam role: %w", err)
 		}
 		c.logger.Info(fmt.Sprintf("%s iam role deleted", roleName))
@@ -254,7 +522,7 @@ func (c Client) DeleteIAMRoles(flowLogs ec2.FlowLogs) error {
 	return nil
 }
 
-func (c Client) DeleteLogGroups(flowLogs ec2.FlowLogs) error {
+func (c Client) DeleteLogGroups(ctx context.Context, flowLogs ec2.FlowLogs) error {
 	if len(flowLogs) == 0 {
 		c.logger.Info("no flow logs provided, nothing to delete")
 		return nil
@@ -270,7 +538,7 @@ func (c Client) DeleteLogGroups(flowLogs ec2.FlowLogs) error {
 		tags := flowLogsByName[name][0].Tags()
 		delete(tags, "Name")
 		logGroupName := logGroupNameFromFlowLogName(name)
-		if err := c.logsClient.DeleteLogGroup(logGroupName, tags); err != nil {
+		if err := c.logsClient.DeleteLogGroup(ctx, logGroupName, tags); err != nil {
 			return fmt.Errorf("delete log group: %w", err)
 		}
 		c.logger.Info(fmt.Sprintf("%s log group deleted", logGroupName))
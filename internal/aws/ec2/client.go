@@ -27,20 +27,20 @@ func NewClient(logger *slog.Logger, cfg aws.Config) Client {
 	}
 }
 
-func (c Client) ListAllVPCs() (VPCs, error) {
-	return c.listVPCs(nil)
+func (c Client) ListAllVPCs(ctx context.Context) (VPCs, error) {
+	return c.listVPCs(ctx, nil)
 }
 
-func (c Client) ListVPCs(ownerId string) (VPCs, error) {
+func (c Client) ListVPCs(ctx context.Context, ownerId string) (VPCs, error) {
 	filters := []types.Filter{
 		{Name: aws.String("state"), Values: []string{"available"}},
 		{Name: aws.String("owner-id"), Values: []string{ownerId}},
 	}
-	return c.listVPCs(filters)
+	return c.listVPCs(ctx, filters)
 }
 
-func (c Client) listVPCs(filters []types.Filter) (VPCs, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) listVPCs(ctx context.Context, filters []types.Filter) (VPCs, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	in := &ec2.DescribeVpcsInput{Filters: filters}
@@ -60,7 +60,7 @@ func (c Client) listVPCs(filters []types.Filter) (VPCs, error) {
 	return vpcs, nil
 }
 
-func (c Client) CreateVPCFlowLogs(vpc VPC, logGroupName string, roleArn string, tags map[string]string) error {
+func (c Client) CreateVPCFlowLogs(ctx context.Context, vpc VPC, logGroupName string, roleArn string, tags map[string]string) error {
 	in := createFlowLogsInput{
 		resourceType: types.FlowLogsResourceTypeVpc,
 		resourceIds:  []string{vpc.Id},
@@ -68,24 +68,24 @@ func (c Client) CreateVPCFlowLogs(vpc VPC, logGroupName string, roleArn string,
 		roleArn:      roleArn,
 		tags:         tags,
 	}
-	return c.createFlowLogsV2V7(in)
+	return c.createFlowLogsV2V7(ctx, in)
 }
 
-func (c Client) ListAllSubnets() (Subnets, error) {
-	return c.listSubnets(nil)
+func (c Client) ListAllSubnets(ctx context.Context) (Subnets, error) {
+	return c.listSubnets(ctx, nil)
 }
 
-func (c Client) ListSubnets(ownerId, vpcId string) (Subnets, error) {
+func (c Client) ListSubnets(ctx context.Context, ownerId, vpcId string) (Subnets, error) {
 	filters := []types.Filter{
 		{Name: aws.String("state"), Values: []string{"available"}},
 		{Name: aws.String("owner-id"), Values: []string{ownerId}},
 		{Name: aws.String("vpc-id"), Values: []string{vpcId}},
 	}
-	return c.listSubnets(filters)
+	return c.listSubnets(ctx, filters)
 }
 
-func (c Client) listSubnets(filters []types.Filter) (Subnets, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) listSubnets(ctx context.Context, filters []types.Filter) (Subnets, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	in := &ec2.DescribeSubnetsInput{Filters: filters}
@@ -105,7 +105,7 @@ func (c Client) listSubnets(filters []types.Filter) (Subnets, error) {
 	return subnets, nil
 }
 
-func (c Client) CreateSubnetFlowLogs(subnet Subnet, logGroupName string, roleArn string, tags map[string]string) error {
+func (c Client) CreateSubnetFlowLogs(ctx context.Context, subnet Subnet, logGroupName string, roleArn string, tags map[string]string) error {
 	in := createFlowLogsInput{
 		resourceType: types.FlowLogsResourceTypeSubnet,
 		resourceIds:  []string{subnet.Id},
@@ -113,19 +113,19 @@ func (c Client) CreateSubnetFlowLogs(subnet Subnet, logGroupName string, roleArn
 		roleArn:      roleArn,
 		tags:         tags,
 	}
-	return c.createFlowLogsV2V7(in)
+	return c.createFlowLogsV2V7(ctx, in)
 }
 
-func (c Client) ListVPCEndpoints() (VPCEndpoints, error) {
+func (c Client) ListVPCEndpoints(ctx context.Context) (VPCEndpoints, error) {
 	filters := []types.Filter{
 		{Name: aws.String("vpc-endpoint-state"), Values: []string{"available"}},
 		{Name: aws.String("vpc-endpoint-type"), Values: []string{"Interface"}},
 	}
-	return c.listVPCEndpoints(filters)
+	return c.listVPCEndpoints(ctx, filters)
 }
 
-func (c Client) listVPCEndpoints(filters []types.Filter) (VPCEndpoints, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) listVPCEndpoints(ctx context.Context, filters []types.Filter) (VPCEndpoints, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	in := &ec2.DescribeVpcEndpointsInput{Filters: filters}
@@ -145,7 +145,7 @@ func (c Client) listVPCEndpoints(filters []types.Filter) (VPCEndpoints, error) {
 	return endpoints, nil
 }
 
-func (c Client) CreateVPCEndpointFlowLogs(endpoint VPCEndpoint, logGroupName string, roleArn string, tags map[string]string) error {
+func (c Client) CreateVPCEndpointFlowLogs(ctx context.Context, endpoint VPCEndpoint, logGroupName string, roleArn string, tags map[string]string) error {
 	in := createFlowLogsInput{
 		resourceType: types.FlowLogsResourceTypeNetworkInterface,
 		resourceIds:  endpoint.NetworkInterfaceIds,
@@ -153,11 +153,11 @@ func (c Client) CreateVPCEndpointFlowLogs(endpoint VPCEndpoint, logGroupName str
 		roleArn:      roleArn,
 		tags:         tags,
 	}
-	return c.createFlowLogsV2V7(in)
+	return c.createFlowLogsV2V7(ctx, in)
 }
 
-func (c Client) ListNatGateways(vpcId string) (NatGateways, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) ListNatGateways(ctx context.Context, vpcId string) (NatGateways, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	filters := []types.Filter{
@@ -183,7 +183,7 @@ func (c Client) ListNatGateways(vpcId string) (NatGateways, error) {
 	return natGateways, nil
 }
 
-func (c Client) CreateNatGatewayFlowLogs(natGateway NatGateway, logGroupName string, roleArn string, tags map[string]string) error {
+func (c Client) CreateNatGatewayFlowLogs(ctx context.Context, natGateway NatGateway, logGroupName string, roleArn string, tags map[string]string) error {
 	in := createFlowLogsInput{
 		resourceType: types.FlowLogsResourceTypeNetworkInterface,
 		resourceIds:  []string{natGateway.NetworkInterfaceId},
@@ -191,11 +191,11 @@ func (c Client) CreateNatGatewayFlowLogs(natGateway NatGateway, logGroupName str
 		roleArn:      roleArn,
 		tags:         tags,
 	}
-	return c.createFlowLogsV2V7(in)
+	return c.createFlowLogsV2V7(ctx, in)
 }
 
-func (c Client) ListSecurityGroups(ownerId, vpcId string) (SecurityGroups, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) ListSecurityGroups(ctx context.Context, ownerId, vpcId string) (SecurityGroups, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	filters := []types.Filter{
@@ -220,8 +220,8 @@ func (c Client) ListSecurityGroups(ownerId, vpcId string) (SecurityGroups, error
 	return securityGroups, nil
 }
 
-func (c Client) CreateSecurityGroupFlowLogs(securityGroup SecurityGroup, logGroupName string, roleArn string, tags map[string]string) error {
-	networkInterfaceIds, err := c.ListSecurityGroupNetworkInterfaceIds(securityGroup)
+func (c Client) CreateSecurityGroupFlowLogs(ctx context.Context, securityGroup SecurityGroup, logGroupName string, roleArn string, tags map[string]string) error {
+	networkInterfaceIds, err := c.ListSecurityGroupNetworkInterfaceIds(ctx, securityGroup)
 	if err != nil {
 		return err
 	}
@@ -233,11 +233,11 @@ func (c Client) CreateSecurityGroupFlowLogs(securityGroup SecurityGroup, logGrou
 		roleArn:      roleArn,
 		tags:         tags,
 	}
-	return c.createFlowLogsV2V7(in)
+	return c.createFlowLogsV2V7(ctx, in)
 }
 
-func (c Client) ListInstances(vpcId string) (Instances, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) ListInstances(ctx context.Context, vpcId string) (Instances, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	filters := []types.Filter{
@@ -263,7 +263,7 @@ func (c Client) ListInstances(vpcId string) (Instances, error) {
 	return instances, nil
 }
 
-func (c Client) CreateInstancesFlowLogs(instances Instances, logGroupName string, roleArn string, tags map[string]string) error {
+func (c Client) CreateInstancesFlowLogs(ctx context.Context, instances Instances, logGroupName string, roleArn string, tags map[string]string) error {
 	var networkInterfaceIds []string
 	for _, v := range instances {
 		networkInterfaceIds = append(networkInterfaceIds, v.NetworkInterfaceIds...)
@@ -276,7 +276,7 @@ func (c Client) CreateInstancesFlowLogs(instances Instances, logGroupName string
 		roleArn:      roleArn,
 		tags:         tags,
 	}
-	return c.createFlowLogsV2V7(in)
+	return c.createFlowLogsV2V7(ctx, in)
 }
 
 type createFlowLogsInput struct {
@@ -305,8 +305,8 @@ func (c createFlowLogsInput) toInput(logFormat query.FlowLogFields) *ec2.CreateF
 	}
 }
 
-func (c Client) createFlowLogsV2V7(in createFlowLogsInput) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) createFlowLogsV2V7(ctx context.Context, in createFlowLogsInput) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	// try to create flow logs with V7 (ECS) fields
@@ -317,7 +317,7 @@ func (c Client) createFlowLogsV2V7(in createFlowLogsInput) error {
 			// full message is: Caller is not authorized to obtain ECS field(s). Failed with error: {A minimum of 1 ECS Cluster is required to Create Flow Logs with ECS Fields}
 			// create flow logs without V7 fields (ECS)
 			if strings.HasPrefix(apiErr.ErrorMessage(), "Caller is not authorized to obtain ECS field(s).") {
-				return c.createFlowLogsV2V5(in)
+				return c.createFlowLogsV2V5(ctx, in)
 			}
 		}
 		return err
@@ -325,8 +325,8 @@ func (c Client) createFlowLogsV2V7(in createFlowLogsInput) error {
 	return nil
 }
 
-func (c Client) createFlowLogsV2V5(in createFlowLogsInput) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) createFlowLogsV2V5(ctx context.Context, in createFlowLogsInput) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	params := in.toInput(query.FlowLogFieldsV2V5)
@@ -336,9 +336,34 @@ func (c Client) createFlowLogsV2V5(in createFlowLogsInput) error {
 	return nil
 }
 
+// ListCloudWatchFlowLogs returns all flow logs in the region that are delivered to CloudWatch logs, including flow logs
+// not created by cli
+func (c Client) ListCloudWatchFlowLogs(ctx context.Context) (FlowLogs, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	in := &ec2.DescribeFlowLogsInput{
+		Filter: []types.Filter{{Name: aws.String("log-destination-type"), Values: []string{"cloud-watch-logs"}}},
+	}
+
+	var flowLogs FlowLogs
+	for {
+		out, err := c.svc.DescribeFlowLogs(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		flowLogs = append(flowLogs, toFlowLogs(out.FlowLogs)...)
+		if aws.ToString(out.NextToken) == "" {
+			break
+		}
+		in.NextToken = out.NextToken
+	}
+	return flowLogs, nil
+}
+
 // ListFlowLogs flow logs that match supplied tags and name (tag Name) prefix
-func (c Client) ListFlowLogs(namePrefix string, tags map[string]string) (FlowLogs, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) ListFlowLogs(ctx context.Context, namePrefix string, tags map[string]string) (FlowLogs, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	// delete 'Name' from tags, we are using 'Name' as tag-key
@@ -383,8 +408,8 @@ func (c Client) ListFlowLogs(namePrefix string, tags map[string]string) (FlowLog
 	return filteredFlowLogs, nil
 }
 
-func (c Client) DeleteFlowLogs(flowLogIds []string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) DeleteFlowLogs(ctx context.Context, flowLogIds []string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	in := &ec2.DeleteFlowLogsInput{FlowLogIds: flowLogIds}
@@ -393,13 +418,13 @@ func (c Client) DeleteFlowLogs(flowLogIds []string) error {
 	return err
 }
 
-func (c Client) ListSecurityGroupNetworkInterfaceIds(securityGroup SecurityGroup) ([]string, error) {
+func (c Client) ListSecurityGroupNetworkInterfaceIds(ctx context.Context, securityGroup SecurityGroup) ([]string, error) {
 	filters := []types.Filter{
 		{Name: aws.String("vpc-id"), Values: []string{securityGroup.VpcId}},
 		{Name: aws.String("group-id"), Values: []string{securityGroup.Id}},
 	}
 
-	nis, err := c.describeNetworkInterfaces(filters)
+	nis, err := c.describeNetworkInterfaces(ctx, filters)
 	if err != nil {
 		return nil, fmt.Errorf("list network interfaces for %s security group: %w", securityGroup.Id, err)
 	}
@@ -411,12 +436,12 @@ func (c Client) ListSecurityGroupNetworkInterfaceIds(securityGroup SecurityGroup
 	return ids, nil
 }
 
-func (c Client) ListNetworkInterfaces() (NetworkInterfaces, error) {
-	return c.describeNetworkInterfaces(nil)
+func (c Client) ListNetworkInterfaces(ctx context.Context) (NetworkInterfaces, error) {
+	return c.describeNetworkInterfaces(ctx, nil)
 }
 
-func (c Client) describeNetworkInterfaces(filters []types.Filter) (NetworkInterfaces, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) describeNetworkInterfaces(ctx context.Context, filters []types.Filter) (NetworkInterfaces, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	in := &ec2.DescribeNetworkInterfacesInput{Filters: filters}
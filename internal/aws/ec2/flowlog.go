@@ -7,6 +7,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/pete911/flowlogs/internal/aws/query"
 )
 
 type FlowLogs []FlowLog
@@ -27,6 +28,22 @@ func (f FlowLogs) NamesSet() map[string]struct{} {
 	return out
 }
 
+// GetByLogGroupNames returns sorted log group names (keys) and map where key is log group name and value flow logs
+func (f FlowLogs) GetByLogGroupNames() ([]string, map[string]FlowLogs) {
+	var names []string
+	out := make(map[string]FlowLogs)
+	for _, flowLog := range f {
+		name := flowLog.LogGroupName
+		if _, ok := out[name]; !ok {
+			out[name] = FlowLogs{}
+			names = append(names, name)
+		}
+		out[name] = append(out[name], flowLog)
+	}
+	slices.Sort(names)
+	return names, out
+}
+
 // GetByNames returns sorted names (keys) and map where key is name and value flow logs
 func (f FlowLogs) GetByNames() ([]string, map[string]FlowLogs) {
 	var names []string
@@ -56,6 +73,7 @@ type FlowLog struct {
 	FlowLogId    string
 	ResourceId   string
 	LogGroupName string
+	LogFormat    string
 	CreationTime time.Time
 	tags         map[string]string
 }
@@ -75,11 +93,21 @@ func toFlowLog(in types.FlowLog) FlowLog {
 		FlowLogId:    aws.ToString(in.FlowLogId),
 		ResourceId:   aws.ToString(in.ResourceId),
 		LogGroupName: aws.ToString(in.LogGroupName),
+		LogFormat:    aws.ToString(in.LogFormat),
 		CreationTime: aws.ToTime(in.CreationTime),
 		tags:         tags,
 	}
 }
 
+// Fields returns flow log fields from the log format
+func (f FlowLog) Fields() query.FlowLogFields {
+	return query.ParseLogFormat(f.LogFormat)
+}
+
 func (f FlowLog) String() string {
+	if f.Name == "" {
+		// flow logs not created by cli do not have to have name
+		return fmt.Sprintf("%s [%s - %s]", f.LogGroupName, f.FlowLogId, f.ResourceId)
+	}
 	return fmt.Sprintf("%s [%s - %s]", f.Name, f.FlowLogId, f.ResourceId)
 }
@@ -110,6 +110,11 @@ func (v NetworkInterfaces) GetByIp(ip string) NetworkInterfaces {
 	return out
 }
 
+// HasIp returns true if the ip is one of the network interface addresses
+func (v NetworkInterface) HasIp(ip string) bool {
+	return v.matchesIp(func(in string) bool { return in == ip })
+}
+
 func (v NetworkInterface) matchesIp(matcher func(in string) bool) bool {
 	// private ip address is already in private ip addresses slice, but just in case check all
 	for _, ip := range append(v.PrivateIpAddresses, v.PrivateIpAddress, v.PublicIP) {
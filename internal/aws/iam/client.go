@@ -57,8 +57,8 @@ func NewClient(logger *slog.Logger, cfg aws.Config) Client {
 }
 
 // CreateFlowLogsRole create role and return arn
-func (c Client) CreateFlowLogsRole(roleName string, tags map[string]string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) CreateFlowLogsRole(ctx context.Context, roleName string, tags map[string]string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	in := &iam.CreateRoleInput{
@@ -72,14 +72,14 @@ func (c Client) CreateFlowLogsRole(roleName string, tags map[string]string) (str
 	if err != nil {
 		return "", err
 	}
-	if err := c.putRolePolicy(roleName, policyName, policy); err != nil {
+	if err := c.putRolePolicy(ctx, roleName, policyName, policy); err != nil {
 		return "", fmt.Errorf("put %s role policy: %w", roleName, err)
 	}
 	return aws.ToString(out.Role.Arn), nil
 }
 
-func (c Client) putRolePolicy(roleName, policyName, policyDocument string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) putRolePolicy(ctx context.Context, roleName, policyName, policyDocument string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	in := &iam.PutRolePolicyInput{
@@ -94,20 +94,20 @@ func (c Client) putRolePolicy(roleName, policyName, policyDocument string) error
 	return nil
 }
 
-func (c Client) DeleteRole(roleName string, tags map[string]string) error {
+func (c Client) DeleteRole(ctx context.Context, roleName string, tags map[string]string) error {
 	// make sure we are deleting the right role, check tags before deletion
-	if err := c.roleMatches(roleName, tags); err != nil {
+	if err := c.roleMatches(ctx, roleName, tags); err != nil {
 		return err
 	}
 	c.logger.Debug(fmt.Sprintf("role %s matches supplied tags", roleName))
 
 	// delete policy first, otherwise we get 'DeleteConflict: Cannot delete entity, must delete policies first' error
-	if err := c.deleteRolePolicy(roleName, policyName); err != nil {
+	if err := c.deleteRolePolicy(ctx, roleName, policyName); err != nil {
 		return err
 	}
 	c.logger.Debug(fmt.Sprintf("role %s policy %s deleted", roleName, policyName))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	in := &iam.DeleteRoleInput{RoleName: aws.String(roleName)}
@@ -118,8 +118,8 @@ func (c Client) DeleteRole(roleName string, tags map[string]string) error {
 	return nil
 }
 
-func (c Client) deleteRolePolicy(roleName, policyName string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) deleteRolePolicy(ctx context.Context, roleName, policyName string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	in := &iam.DeleteRolePolicyInput{
@@ -133,8 +133,8 @@ func (c Client) deleteRolePolicy(roleName, policyName string) error {
 	return nil
 }
 
-func (c Client) roleMatches(roleName string, tags map[string]string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) roleMatches(ctx context.Context, roleName string, tags map[string]string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	in := &iam.GetRoleInput{RoleName: aws.String(roleName)}
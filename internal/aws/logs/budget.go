@@ -0,0 +1,66 @@
+package logs
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrScanBudgetExceeded is returned when query is stopped, because queries scanned more bytes than the scan budget
+var ErrScanBudgetExceeded = errors.New("scan budget exceeded")
+
+// ScanBudget limits bytes scanned by all queries run by the client (including concurrent queries). Nil budget does not
+// limit queries.
+type ScanBudget struct {
+	maxBytes float64
+	mu       sync.Mutex
+	// completed is number of bytes scanned by queries that are not running anymore
+	completed float64
+	// running is number of bytes scanned so far by running queries, keyed by query id
+	running map[string]float64
+}
+
+func NewScanBudget(maxBytes float64) *ScanBudget {
+	return &ScanBudget{maxBytes: maxBytes, running: make(map[string]float64)}
+}
+
+// MaxBytes returns maximum number of bytes that can be scanned, zero for nil budget
+func (b *ScanBudget) MaxBytes() float64 {
+	if b == nil {
+		return 0
+	}
+	return b.maxBytes
+}
+
+// update sets bytes scanned by the running query and returns false if all queries scanned more than budget allows
+func (b *ScanBudget) update(queryId string, bytes float64) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.running[queryId] = bytes
+	return b.scanned() <= b.maxBytes
+}
+
+// complete moves bytes scanned by the query from running to completed queries
+func (b *ScanBudget) complete(queryId string, bytes float64) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.running, queryId)
+	b.completed += bytes
+}
+
+func (b *ScanBudget) scanned() float64 {
+	out := b.completed
+	for _, bytes := range b.running {
+		out += bytes
+	}
+	return out
+}
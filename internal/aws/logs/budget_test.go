@@ -0,0 +1,101 @@
+package logs
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestScanBudget(t *testing.T) {
+	type step struct {
+		queryId  string
+		bytes    float64
+		complete bool
+		want     bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "single query within budget",
+			steps: []step{
+				{queryId: "q1", bytes: 40, want: true},
+				{queryId: "q1", bytes: 100, want: true},
+			},
+		},
+		{
+			name: "running query updates replace its bytes",
+			steps: []step{
+				{queryId: "q1", bytes: 90, want: true},
+				{queryId: "q1", bytes: 95, want: true},
+				{queryId: "q1", bytes: 101, want: false},
+			},
+		},
+		{
+			name: "concurrent running queries are summed",
+			steps: []step{
+				{queryId: "q1", bytes: 60, want: true},
+				{queryId: "q2", bytes: 40, want: true},
+				{queryId: "q2", bytes: 41, want: false},
+			},
+		},
+		{
+			name: "completed queries are summed with running queries",
+			steps: []step{
+				{queryId: "q1", bytes: 50, complete: true},
+				{queryId: "q2", bytes: 30, complete: true},
+				{queryId: "q3", bytes: 20, want: true},
+				{queryId: "q3", bytes: 21, want: false},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := NewScanBudget(100)
+			for i, s := range tc.steps {
+				if s.complete {
+					b.complete(s.queryId, s.bytes)
+					continue
+				}
+				if got := b.update(s.queryId, s.bytes); got != s.want {
+					t.Errorf("step %d: update(%s, %v) = %t, want %t", i, s.queryId, s.bytes, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestScanBudgetConcurrentQueries(t *testing.T) {
+	b := NewScanBudget(1000)
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Go(func() {
+			queryId := fmt.Sprintf("q%d", i)
+			for bytes := 10.0; bytes <= 50; bytes += 10 {
+				b.update(queryId, bytes)
+			}
+			b.complete(queryId, 50)
+		})
+	}
+	wg.Wait()
+
+	if got := b.scanned(); got != 500 {
+		t.Errorf("scanned() = %v, want 500", got)
+	}
+	if !b.update("q10", 500) || b.update("q10", 501) {
+		t.Error("update() should allow exactly the remaining budget")
+	}
+}
+
+func TestScanBudgetNil(t *testing.T) {
+	var b *ScanBudget
+	if !b.update("q1", 1e15) {
+		t.Error("nil budget should not limit queries")
+	}
+	b.complete("q1", 1e15)
+	if b.MaxBytes() != 0 {
+		t.Errorf("MaxBytes() = %v, want 0", b.MaxBytes())
+	}
+}
@@ -2,20 +2,84 @@ package logs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/pete911/flowlogs/internal/aws/query"
 )
 
-const retentionDays = 30
+const (
+	retentionDays = 30
+	// maxQueryResults is the maximum number of results Logs Insights query can return
+	maxQueryResults = 10000
+	// maxConcurrentQueries is kept well below Logs Insights concurrent queries quota, so other users in the account
+	// can still run queries
+	maxConcurrentQueries = 10
+	// MaxQueryLogGroups is the maximum number of log groups in a single Logs Insights query
+	MaxQueryLogGroups = 50
+	// maxLiveTailLogGroups is the maximum number of log groups in a single live tail session
+	maxLiveTailLogGroups = 10
+	// BytesPerGB is number of bytes in GB, as used by Logs Insights pricing
+	BytesPerGB = 1000 * 1000 * 1000
+	// minPollInterval and maxPollInterval bound the interval between query results polls
+	minPollInterval = time.Second
+	maxPollInterval = 30 * time.Second
+)
+
+// QueryStatistics is Logs Insights query progress, values are for the whole query (not for the last poll)
+type QueryStatistics struct {
+	RecordsScanned float64
+	BytesScanned   float64
+	RecordsMatched float64
+}
+
+// Add returns sum of statistics, e.g. statistics of multiple queries
+func (s QueryStatistics) Add(in QueryStatistics) QueryStatistics {
+	return QueryStatistics{
+		RecordsScanned: s.RecordsScanned + in.RecordsScanned,
+		BytesScanned:   s.BytesScanned + in.BytesScanned,
+		RecordsMatched: s.RecordsMatched + in.RecordsMatched,
+	}
+}
+
+// QueryProgress is called every time query results are polled while the query is running (and once it is complete),
+// with rows that were not reported yet (de-duplicated by @ptr) and current query statistics. Rows without @ptr (e.g.
+// stats results, that change while the query is running) are not reported.
+type QueryProgress func(rows []map[string]string, stats QueryStatistics)
+
+// LogEvent is single log event received from live tail
+type LogEvent struct {
+	LogGroup  string
+	Timestamp time.Time
+	Message   string
+}
+
+// cloudWatchLogsAPI is the subset of cloudwatchlogs client used by the client
+type cloudWatchLogsAPI interface {
+	CreateLogGroup(ctx context.Context, params *cloudwatchlogs.CreateLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error)
+	PutRetentionPolicy(ctx context.Context, params *cloudwatchlogs.PutRetentionPolicyInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error)
+	DeleteLogGroup(ctx context.Context, params *cloudwatchlogs.DeleteLogGroupInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DeleteLogGroupOutput, error)
+	DescribeLogGroups(ctx context.Context, params *cloudwatchlogs.DescribeLogGroupsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error)
+	ListTagsForResource(ctx context.Context, params *cloudwatchlogs.ListTagsForResourceInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.ListTagsForResourceOutput, error)
+	StartQuery(ctx context.Context, params *cloudwatchlogs.StartQueryInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartQueryOutput, error)
+	GetQueryResults(ctx context.Context, params *cloudwatchlogs.GetQueryResultsInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error)
+	StopQuery(ctx context.Context, params *cloudwatchlogs.StopQueryInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StopQueryOutput, error)
+	StartLiveTail(ctx context.Context, params *cloudwatchlogs.StartLiveTailInput, optFns ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StartLiveTailOutput, error)
+}
 
 type Client struct {
 	logger *slog.Logger
-	svc    *cloudwatchlogs.Client
+	svc    cloudWatchLogsAPI
+	budget *ScanBudget
 }
 
 func NewClient(logger *slog.Logger, cfg aws.Config) Client {
@@ -25,8 +89,8 @@ func NewClient(logger *slog.Logger, cfg aws.Config) Client {
 	}
 }
 
-func (c Client) CreateLogGroup(logGroupName string, tags map[string]string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) CreateLogGroup(ctx context.Context, logGroupName string, tags map[string]string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	in := &cloudwatchlogs.CreateLogGroupInput{
@@ -39,14 +103,14 @@ func (c Client) CreateLogGroup(logGroupName string, tags map[string]string) erro
 		return err
 	}
 
-	if err := c.putRetentionPolicy(logGroupName, retentionDays); err != nil {
+	if err := c.putRetentionPolicy(ctx, logGroupName, retentionDays); err != nil {
 		return fmt.Errorf("put retention policy on %s log group for %d days: %w", logGroupName, retentionDays, err)
 	}
 	return nil
 }
 
-func (c Client) putRetentionPolicy(logGroupName string, retentionDays int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) putRetentionPolicy(ctx context.Context, logGroupName string, retentionDays int) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	in := &cloudwatchlogs.PutRetentionPolicyInput{
@@ -58,13 +122,13 @@ func (c Client) putRetentionPolicy(logGroupName string, retentionDays int) error
 	return err
 }
 
-func (c Client) DeleteLogGroup(name string, tags map[string]string) error {
+func (c Client) DeleteLogGroup(ctx context.Context, name string, tags map[string]string) error {
 	// make sure we are deleting correct log group, validate tags as well
-	if err := c.logGroupMatches(name, tags); err != nil {
+	if err := c.logGroupMatches(ctx, name, tags); err != nil {
 		return err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	in := &cloudwatchlogs.DeleteLogGroupInput{LogGroupName: aws.String(name)}
@@ -73,14 +137,14 @@ func (c Client) DeleteLogGroup(name string, tags map[string]string) error {
 	return err
 }
 
-func (c Client) logGroupMatches(logGroupName string, tags map[string]string) error {
-	logGroup, err := c.describeLogGroup(logGroupName)
+func (c Client) logGroupMatches(ctx context.Context, logGroupName string, tags map[string]string) error {
+	logGroup, err := c.describeLogGroup(ctx, logGroupName)
 	if err != nil {
 		return err
 	}
 
 	// list and compare tags
-	logGroupTags, err := c.listTags(logGroup.LogGroupArn)
+	logGroupTags, err := c.listTags(ctx, logGroup.LogGroupArn)
 	if err != nil {
 		return err
 	}
@@ -99,8 +163,8 @@ func (c Client) logGroupMatches(logGroupName string, tags map[string]string) err
 	return nil
 }
 
-func (c Client) describeLogGroup(logGroupName string) (LogGroup, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) describeLogGroup(ctx context.Context, logGroupName string) (LogGroup, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	in := &cloudwatchlogs.DescribeLogGroupsInput{LogGroupNamePrefix: aws.String(logGroupName)}
@@ -126,8 +190,8 @@ func (c Client) describeLogGroup(logGroupName string) (LogGroup, error) {
 	return LogGroup{}, fmt.Errorf("log group %s not found", logGroupName)
 }
 
-func (c Client) listTags(logGroupArn string) (map[string]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) listTags(ctx context.Context, logGroupArn string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	in := &cloudwatchlogs.ListTagsForResourceInput{ResourceArn: aws.String(logGroupArn)}
@@ -139,8 +203,8 @@ func (c Client) listTags(logGroupArn string) (map[string]string, error) {
 	return out.Tags, nil
 }
 
-func (c Client) ListLogGroups(logGroupNamePrefix string) ([]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (c Client) ListLogGroups(ctx context.Context, logGroupNamePrefix string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	in := &cloudwatchlogs.DescribeLogGroupsInput{LogGroupNamePrefix: aws.String(logGroupNamePrefix)}
@@ -162,56 +226,309 @@ func (c Client) ListLogGroups(logGroupNamePrefix string) ([]string, error) {
 	return logGroups, nil
 }
 
-func (c Client) Query(logGroupNames []string, queryString string, sinceMinutes, limit int) ([]map[string]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
+func (c Client) Query(ctx context.Context, logGroupNames []string, queryString string, start, end time.Time, limit int, progress QueryProgress) ([]map[string]string, QueryStatistics, error) {
 	in := &cloudwatchlogs.StartQueryInput{
-		EndTime:       aws.Int64(time.Now().Unix()),
-		StartTime:     aws.Int64(time.Now().Add(time.Duration(-sinceMinutes) * time.Minute).Unix()),
+		EndTime:       aws.Int64(end.Unix()),
+		StartTime:     aws.Int64(start.Unix()),
 		QueryString:   aws.String(queryString),
 		Limit:         aws.Int32(int32(limit)),
 		LogGroupNames: logGroupNames,
 	}
 
-	out, err := c.svc.StartQuery(ctx, in)
+	startCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	out, err := c.svc.StartQuery(startCtx, in)
 	if err != nil {
-		return nil, err
+		return nil, QueryStatistics{}, err
 	}
-	return c.getQueryResults(aws.ToString(out.QueryId))
+	return c.getQueryResults(ctx, aws.ToString(out.QueryId), progress)
 }
 
-func (c Client) getQueryResults(queryId string) ([]map[string]string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
-	defer cancel()
+// WithScanBudget returns client that stops queries once all queries run by the client scan more bytes than the budget
+func (c Client) WithScanBudget(budget *ScanBudget) Client {
+	c.budget = budget
+	return c
+}
+
+// EstimateScanBytes estimates number of bytes scanned by query of log groups in the time range (see
+// LogGroup.EstimateScanBytes)
+func (c Client) EstimateScanBytes(ctx context.Context, logGroupNames []string, start, end time.Time) (float64, error) {
+	now := time.Now()
+	var out float64
+	for _, name := range logGroupNames {
+		logGroup, err := c.describeLogGroup(ctx, name)
+		if err != nil {
+			return 0, err
+		}
+		out += logGroup.EstimateScanBytes(start, end, now)
+	}
+	return out, nil
+}
+
+// QueryAll returns all results in the time range, not limited by the Logs Insights results cap. The time range is split
+// to windows that are queried concurrently, windows that hit the cap are bisected and queried again. Results are
+// merged and sorted by timestamp (newest first). Statistics are sum of all queries, including bisected windows.
+func (c Client) QueryAll(ctx context.Context, logGroupNames []string, queryString string, start, end time.Time) ([]map[string]string, QueryStatistics, error) {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results []map[string]string
+		stats   QueryStatistics
+		errs    []error
+	)
+	sem := make(chan struct{}, maxConcurrentQueries)
+
+	var queryWindow func(w query.Window)
+	queryWindow = func(w query.Window) {
+		defer wg.Done()
+
+		sem <- struct{}{}
+		out, windowStats, err := c.Query(ctx, logGroupNames, queryString, w.Start, w.End, maxQueryResults, nil)
+		<-sem
+		mu.Lock()
+		stats = stats.Add(windowStats)
+		mu.Unlock()
+		if err != nil {
+			mu.Lock()
+			errs = append(errs, fmt.Errorf("window %s - %s: %w", w.Start.Format(time.RFC3339), w.End.Format(time.RFC3339), err))
+			mu.Unlock()
+			return
+		}
+
+		if len(out) >= maxQueryResults {
+			if a, b, ok := w.Bisect(); ok {
+				c.logger.Debug(fmt.Sprintf("window %s - %s returned %d results, bisecting", w.Start.Format(time.RFC3339), w.End.Format(time.RFC3339), len(out)))
+				wg.Add(2)
+				go queryWindow(a)
+				go queryWindow(b)
+				return
+			}
+			c.logger.Warn(fmt.Sprintf("window %s returned %d results and cannot be split further, results are truncated", w.Start.Format(time.RFC3339), len(out)))
+		}
 
+		mu.Lock()
+		results = append(results, out...)
+		mu.Unlock()
+	}
+
+	for _, w := range query.NewWindow(start, end).Split(maxConcurrentQueries) {
+		wg.Add(1)
+		go queryWindow(w)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, stats, err
+	}
+	// timestamp format (2024-12-04 14:50:07.000) can be sorted as a string
+	slices.SortStableFunc(results, func(a, b map[string]string) int {
+		return strings.Compare(b["@timestamp"], a["@timestamp"])
+	})
+	return results, stats, nil
+}
+
+// Tail streams log events from log groups (live tail) and calls handler for every batch of received events. Tail
+// blocks until all live tail sessions are closed (sessions are closed by AWS after 3 hours) or fail.
+func (c Client) Tail(ctx context.Context, logGroupNames []string, handler func(events []LogEvent)) error {
+	var arns []string
+	for _, name := range logGroupNames {
+		logGroup, err := c.describeLogGroup(ctx, name)
+		if err != nil {
+			return err
+		}
+		arns = append(arns, logGroup.LogGroupArn)
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for chunk := range slices.Chunk(arns, maxLiveTailLogGroups) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := c.liveTail(ctx, chunk, func(events []LogEvent) {
+				mu.Lock()
+				defer mu.Unlock()
+				handler(events)
+			})
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (c Client) liveTail(ctx context.Context, logGroupArns []string, handler func(events []LogEvent)) error {
+	in := &cloudwatchlogs.StartLiveTailInput{LogGroupIdentifiers: logGroupArns}
+	out, err := c.svc.StartLiveTail(ctx, in)
+	if err != nil {
+		return fmt.Errorf("start live tail: %w", err)
+	}
+
+	stream := out.GetStream()
+	defer stream.Close()
+
+	for {
+		var event types.StartLiveTailResponseStream
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-stream.Events():
+			if !ok {
+				if err := stream.Err(); err != nil {
+					return fmt.Errorf("live tail: %w", err)
+				}
+				return nil
+			}
+			event = e
+		}
+
+		switch e := event.(type) {
+		case *types.StartLiveTailResponseStreamMemberSessionStart:
+			c.logger.Debug(fmt.Sprintf("live tail session %s started", aws.ToString(e.Value.SessionId)))
+		case *types.StartLiveTailResponseStreamMemberSessionUpdate:
+			if e.Value.SessionMetadata != nil && e.Value.SessionMetadata.Sampled {
+				c.logger.Warn("live tail received more than 500 events per second, events are sampled")
+			}
+			if len(e.Value.SessionResults) > 0 {
+				handler(toLogEvents(e.Value.SessionResults))
+			}
+		default:
+			c.logger.Debug(fmt.Sprintf("live tail unknown event %T", e))
+		}
+	}
+}
+
+func toLogEvents(in []types.LiveTailSessionLogEvent) []LogEvent {
+	var out []LogEvent
+	for _, v := range in {
+		out = append(out, LogEvent{
+			LogGroup:  aws.ToString(v.LogGroupIdentifier),
+			Timestamp: time.UnixMilli(aws.ToInt64(v.Timestamp)),
+			Message:   aws.ToString(v.Message),
+		})
+	}
+	return out
+}
+
+// getQueryResults polls query results until the query completes, polling interval is increased exponentially (with
+// jitter) up to maxPollInterval. Partial results are passed to progress (if not nil) while the query is running. Query
+// is stopped if the context is cancelled or its deadline is exceeded, if the scan budget is exceeded, or if polling
+// fails. Statistics are returned on error as well, because scanned bytes are billed even if the query does not complete.
+func (c Client) getQueryResults(ctx context.Context, queryId string, progress QueryProgress) ([]map[string]string, QueryStatistics, error) {
 	in := cloudwatchlogs.GetQueryResultsInput{QueryId: aws.String(queryId)}
+	reported := make(map[string]struct{})
 
-	// wait before making first call
-	retrySecond := 2
-	time.Sleep(time.Duration(retrySecond) * time.Second)
+	var stats QueryStatistics
+	defer func() { c.budget.complete(queryId, stats.BytesScanned) }()
 
-	var retry int
+	interval := minPollInterval
 	for {
-		if retry > 5 {
-			return nil, fmt.Errorf("retried %d times, query %s failed", retry, queryId)
+		if err := sleep(ctx, jitter(interval)); err != nil {
+			c.stopQuery(queryId)
+			return nil, stats, err
 		}
+
 		out, err := c.svc.GetQueryResults(ctx, &in)
 		if err != nil {
-			return nil, err
+			// query keeps running (and scanning) if it is not stopped
+			c.stopQuery(queryId)
+			if ctx.Err() != nil {
+				return nil, stats, ctx.Err()
+			}
+			return nil, stats, err
 		}
-		// first we check if query is still running (then we retry), or failed (fail fast)
+		stats = toQueryStatistics(out.Statistics)
+		if !c.budget.update(queryId, stats.BytesScanned) && out.Status != types.QueryStatusComplete {
+			c.stopQuery(queryId)
+			return nil, stats, fmt.Errorf("query %s stopped after scanning %.2f GB: %w", queryId, stats.BytesScanned/BytesPerGB, ErrScanBudgetExceeded)
+		}
+
 		// Cancelled , Complete , Failed , Running , Scheduled , Timeout , and Unknown .
-		if out.Status == types.QueryStatusRunning {
-			c.logger.Info(fmt.Sprintf("query status %s, retrying in %d second", out.Status, retrySecond))
-			time.Sleep(time.Duration(retrySecond) * time.Second)
-			retry++
+		switch out.Status {
+		case types.QueryStatusComplete:
+			results := toQueryResults(out.Results)
+			reportProgress(progress, reported, results, stats)
+			return results, stats, nil
+		case types.QueryStatusRunning, types.QueryStatusScheduled:
+			reportProgress(progress, reported, toQueryResults(out.Results), stats)
+			interval = min(interval*2, maxPollInterval)
+			c.logger.Debug(fmt.Sprintf("query %s status %s, retrying in %s", queryId, out.Status, interval))
+		case types.QueryStatusCancelled:
+			return nil, stats, fmt.Errorf("query %s status %s", queryId, out.Status)
+		default:
+			c.stopQuery(queryId)
+			return nil, stats, fmt.Errorf("query %s status %s", queryId, out.Status)
+		}
+	}
+}
+
+// stopQuery stops running query, it uses new context, because the query context is already cancelled
+func (c Client) stopQuery(queryId string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := c.svc.StopQuery(ctx, &cloudwatchlogs.StopQueryInput{QueryId: aws.String(queryId)}); err != nil {
+		c.logger.Warn(fmt.Sprintf("stop query %s: %v", queryId, err))
+		return
+	}
+	c.logger.Info(fmt.Sprintf("query %s stopped", queryId))
+}
+
+// jitter returns random duration between half and full of the supplied duration
+func jitter(d time.Duration) time.Duration {
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// reportProgress passes rows that were not reported yet to progress, reported rows are added to reported @ptr set
+func reportProgress(progress QueryProgress, reported map[string]struct{}, rows []map[string]string, stats QueryStatistics) {
+	if progress == nil {
+		return
+	}
+
+	var newRows []map[string]string
+	for _, row := range rows {
+		ptr, ok := row["@ptr"]
+		if !ok {
 			continue
 		}
-		if out.Status != types.QueryStatusComplete {
-			return nil, fmt.Errorf("query %s status %s", queryId, out.Status)
+		if _, ok := reported[ptr]; ok {
+			continue
 		}
-		return toQueryResults(out.Results), nil
+		reported[ptr] = struct{}{}
+		newRows = append(newRows, row)
+	}
+	progress(newRows, stats)
+}
+
+func toQueryStatistics(in *types.QueryStatistics) QueryStatistics {
+	if in == nil {
+		return QueryStatistics{}
+	}
+	return QueryStatistics{
+		RecordsScanned: in.RecordsScanned,
+		BytesScanned:   in.BytesScanned,
+		RecordsMatched: in.RecordsMatched,
 	}
 }
 
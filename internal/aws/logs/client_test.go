@@ -0,0 +1,64 @@
+package logs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+)
+
+// fakeLogsAPI returns configured GetQueryResults response and counts StopQuery calls, other methods are not implemented
+type fakeLogsAPI struct {
+	cloudWatchLogsAPI
+	status    types.QueryStatus
+	err       error
+	stopCalls atomic.Int32
+}
+
+func (f *fakeLogsAPI) GetQueryResults(_ context.Context, _ *cloudwatchlogs.GetQueryResultsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &cloudwatchlogs.GetQueryResultsOutput{Status: f.status}, nil
+}
+
+func (f *fakeLogsAPI) StopQuery(_ context.Context, _ *cloudwatchlogs.StopQueryInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.StopQueryOutput, error) {
+	f.stopCalls.Add(1)
+	return &cloudwatchlogs.StopQueryOutput{}, nil
+}
+
+func TestGetQueryResultsStopsQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   types.QueryStatus
+		err      error
+		wantStop bool
+	}{
+		{name: "get results error", err: errors.New("throttled"), wantStop: true},
+		{name: "failed", status: types.QueryStatusFailed, wantStop: true},
+		{name: "timeout", status: types.QueryStatusTimeout, wantStop: true},
+		{name: "unknown", status: types.QueryStatusUnknown, wantStop: true},
+		{name: "cancelled", status: types.QueryStatusCancelled, wantStop: false},
+		{name: "complete", status: types.QueryStatusComplete, wantStop: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			svc := &fakeLogsAPI{status: tc.status, err: tc.err}
+			c := Client{logger: slog.New(slog.NewTextHandler(io.Discard, nil)), svc: svc}
+
+			_, _, err := c.getQueryResults(context.Background(), "q1", nil)
+			if (err != nil) != (tc.status != types.QueryStatusComplete) {
+				t.Errorf("getQueryResults() error = %v", err)
+			}
+			if stopped := svc.stopCalls.Load() == 1; stopped != tc.wantStop {
+				t.Errorf("StopQuery called = %t, want %t", stopped, tc.wantStop)
+			}
+		})
+	}
+}
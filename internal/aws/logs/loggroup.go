@@ -28,3 +28,31 @@ func toLogGroup(in types.LogGroup) LogGroup {
 		StoredBytes:     int(aws.ToInt64(in.StoredBytes)),
 	}
 }
+
+// EstimateScanBytes estimates number of bytes Logs Insights query scans in the time range. Stored bytes are assumed to be
+// spread evenly over the retention period (or log group age, if it is shorter), so the estimate is proportion of stored
+// bytes for the time range that overlaps with the retention period.
+func (l LogGroup) EstimateScanBytes(start, end, now time.Time) float64 {
+	from := l.CreationTime
+	if l.RetentionInDays > 0 {
+		if retention := now.AddDate(0, 0, -l.RetentionInDays); retention.After(from) {
+			from = retention
+		}
+	}
+	period := now.Sub(from)
+	if period <= 0 {
+		return float64(l.StoredBytes)
+	}
+
+	if end.After(now) {
+		end = now
+	}
+	if start.Before(from) {
+		start = from
+	}
+	overlap := end.Sub(start)
+	if overlap <= 0 {
+		return 0
+	}
+	return float64(l.StoredBytes) * min(float64(overlap)/float64(period), 1)
+}
@@ -0,0 +1,91 @@
+package logs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLogGroupEstimateScanBytes(t *testing.T) {
+	now := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name     string
+		logGroup LogGroup
+		start    time.Time
+		end      time.Time
+		want     float64
+	}{
+		{
+			name:     "whole log group age",
+			logGroup: LogGroup{CreationTime: now.Add(-10 * day), StoredBytes: 1000},
+			start:    now.Add(-10 * day),
+			end:      now,
+			want:     1000,
+		},
+		{
+			name:     "part of log group age",
+			logGroup: LogGroup{CreationTime: now.Add(-10 * day), StoredBytes: 1000},
+			start:    now.Add(-day),
+			end:      now,
+			want:     100,
+		},
+		{
+			name:     "retention is clamped to log group age",
+			logGroup: LogGroup{CreationTime: now.Add(-10 * day), RetentionInDays: 30, StoredBytes: 1000},
+			start:    now.Add(-5 * day),
+			end:      now,
+			want:     500,
+		},
+		{
+			name:     "retention shorter than log group age",
+			logGroup: LogGroup{CreationTime: now.Add(-100 * day), RetentionInDays: 10, StoredBytes: 1000},
+			start:    now.Add(-5 * day),
+			end:      now,
+			want:     500,
+		},
+		{
+			name:     "start before retention",
+			logGroup: LogGroup{CreationTime: now.Add(-100 * day), RetentionInDays: 10, StoredBytes: 1000},
+			start:    now.Add(-50 * day),
+			end:      now,
+			want:     1000,
+		},
+		{
+			name:     "range before creation",
+			logGroup: LogGroup{CreationTime: now.Add(-10 * day), StoredBytes: 1000},
+			start:    now.Add(-20 * day),
+			end:      now.Add(-15 * day),
+			want:     0,
+		},
+		{
+			name:     "end in the future",
+			logGroup: LogGroup{CreationTime: now.Add(-10 * day), StoredBytes: 1000},
+			start:    now.Add(-day),
+			end:      now.Add(5 * day),
+			want:     100,
+		},
+		{
+			name:     "zero period",
+			logGroup: LogGroup{CreationTime: now, StoredBytes: 1000},
+			start:    now.Add(-day),
+			end:      now,
+			want:     1000,
+		},
+		{
+			name:     "empty range",
+			logGroup: LogGroup{CreationTime: now.Add(-10 * day), StoredBytes: 1000},
+			start:    now.Add(-day),
+			end:      now.Add(-day),
+			want:     0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.logGroup.EstimateScanBytes(tc.start, tc.end, now); got != tc.want {
+				t.Errorf("EstimateScanBytes() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package query
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ValidateAddress validates IPv4/IPv6 address or CIDR (e.g. 10.0.0.1, 10.1.0.0/16, 2001:db8::/32)
+func ValidateAddress(in string) error {
+	if strings.Contains(in, "/") {
+		if _, err := netip.ParsePrefix(in); err != nil {
+			return fmt.Errorf("invalid CIDR %q", in)
+		}
+		return nil
+	}
+	if _, err := netip.ParseAddr(in); err != nil {
+		return fmt.Errorf("invalid IP address %q", in)
+	}
+	return nil
+}
+
+// addressExpression returns filter expression for the field. If the address is CIDR, field is checked to be in the
+// subnet, otherwise field has to be equal to the address.
+func addressExpression(field, addr string) (Expr, error) {
+	if err := ValidateAddress(addr); err != nil {
+		return nil, err
+	}
+	prefix, err := netip.ParsePrefix(addr)
+	if err != nil {
+		return Compare(field, Eq, StringValue(addr)), nil
+	}
+	return Subnet(field, prefix), nil
+}
+
+// addressFilter returns filter that matches if any of the fields matches the address (or CIDR)
+func addressFilter(addr string, fields ...string) (Expr, error) {
+	var exprs []Expr
+	for _, field := range fields {
+		e, err := addressExpression(field, addr)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+	}
+	return Or(exprs...), nil
+}
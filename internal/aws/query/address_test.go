@@ -0,0 +1,69 @@
+package query
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateAddress(t *testing.T) {
+	valid := []string{"10.0.0.1", "10.1.0.0/16", "10.1.2.3/16", "2001:db8::1", "2001:db8::/32", "0.0.0.0/0"}
+	for _, in := range valid {
+		if err := ValidateAddress(in); err != nil {
+			t.Errorf("ValidateAddress(%q) unexpected error: %v", in, err)
+		}
+	}
+
+	invalid := []string{"", "10.0.0", "10.0.0.1/33", "10.1.0.0/", "not-an-ip", `10.0.0.1"`, "2001:db8::/129"}
+	for _, in := range invalid {
+		if err := ValidateAddress(in); err == nil {
+			t.Errorf("ValidateAddress(%q) expected error", in)
+		}
+	}
+}
+
+func TestQueryAddressCIDR(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(Query) Query
+		want string
+	}{
+		{"Address IPv4", func(q Query) Query { return q.Address("10.1.0.0/16") }, `| filter isIpv4InSubnet(srcAddr, "10.1.0.0/16") or isIpv4InSubnet(pktSrcAddr, "10.1.0.0/16") or isIpv4InSubnet(dstAddr, "10.1.0.0/16") or isIpv4InSubnet(pktDstAddr, "10.1.0.0/16")`},
+		{"SourceAddress host bits are masked", func(q Query) Query { return q.SourceAddress("10.1.2.3/16") }, `| filter isIpv4InSubnet(srcAddr, "10.1.0.0/16")`},
+		{"DestinationAddress IPv6", func(q Query) Query { return q.DestinationAddress("2001:db8::/32") }, `| filter isIpInSubnet(dstAddr, "2001:db8::/32")`},
+		{"PktSourceAddress", func(q Query) Query { return q.PktSourceAddress("192.168.0.0/24") }, `| filter isIpv4InSubnet(pktSrcAddr, "192.168.0.0/24")`},
+		{"PktDestinationAddress", func(q Query) Query { return q.PktDestinationAddress("192.168.0.0/24") }, `| filter isIpv4InSubnet(pktDstAddr, "192.168.0.0/24")`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.fn(NewQuery(100, 60)).GetQuery()
+			if !strings.Contains(got, tc.want) {
+				t.Errorf("query missing clause\n  got:  %q\n  want: %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestQueryAddressCIDRMatch(t *testing.T) {
+	row := map[string]string{"srcAddr": "10.1.2.3", "dstAddr": "2001:db8::1", "pktSrcAddr": "-"}
+
+	tests := []struct {
+		name string
+		q    Query
+		want bool
+	}{
+		{"source in subnet", NewQuery(100, 60).SourceAddress("10.1.0.0/16"), true},
+		{"source not in subnet", NewQuery(100, 60).SourceAddress("10.2.0.0/16"), false},
+		{"destination IPv6 in subnet", NewQuery(100, 60).DestinationAddress("2001:db8::/32"), true},
+		{"any address in subnet", NewQuery(100, 60).Address("10.0.0.0/8"), true},
+		{"missing packet address", NewQuery(100, 60).PktSourceAddress("0.0.0.0/0"), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.q.Match(row); got != tc.want {
+				t.Errorf("Match() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
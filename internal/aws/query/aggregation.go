@@ -0,0 +1,165 @@
+package query
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// aggregation result fields
+const (
+	RecordsField = "records"
+	PacketsField = "totalPackets"
+	BytesField   = "totalBytes"
+)
+
+// aggregationFieldByKey maps user facing group by keys to query fields
+var aggregationFieldByKey = map[string]string{
+	"src-addr":  "srcAddr",
+	"dst-addr":  "dstAddr",
+	"src-port":  "srcPort",
+	"dst-port":  "dstPort",
+	"port":      "dstPort",
+	"eni":       "interfaceId",
+	"protocol":  "protocol",
+	"action":    "action",
+	"direction": "flowDirection",
+	"log-group": "@log",
+}
+
+// AggregationKeys returns sorted keys that can be used to group aggregation results
+func AggregationKeys() []string {
+	var keys []string
+	for k := range aggregationFieldByKey {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Aggregation groups flow logs by fields and sums records (count), packets and bytes. Histogram (see NewHistogram)
+// groups flow logs to Bin time buckets as well.
+type Aggregation struct {
+	GroupBy []string
+	SortBy  string
+	Bin     time.Duration
+}
+
+// NewAggregation creates aggregation from group by keys (e.g. src-addr, dst-port) and metric to sort by - bytes,
+// packets or count. Empty group by aggregates all flow logs to a single row.
+func NewAggregation(groupBy []string, sortBy string) (Aggregation, error) {
+	var fields []string
+	for _, key := range groupBy {
+		field, ok := aggregationFieldByKey[strings.ToLower(strings.TrimSpace(key))]
+		if !ok {
+			return Aggregation{}, fmt.Errorf("invalid aggregation key %q, supported keys: %s", key, strings.Join(AggregationKeys(), ", "))
+		}
+		if !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+
+	var sortField string
+	switch strings.ToLower(sortBy) {
+	case "bytes", "":
+		sortField = BytesField
+	case "packets":
+		sortField = PacketsField
+	case "count":
+		sortField = RecordsField
+	default:
+		return Aggregation{}, fmt.Errorf("invalid aggregation metric %q, supported metrics: bytes, packets, count", sortBy)
+	}
+
+	return Aggregation{
+		GroupBy: fields,
+		SortBy:  sortField,
+	}, nil
+}
+
+func (a Aggregation) stats() string {
+	stats := fmt.Sprintf("| stats count(*) as %s, sum(packets) as %s, sum(bytes) as %s", RecordsField, PacketsField, BytesField)
+	groupBy := a.GroupBy
+	if a.IsHistogram() {
+		groupBy = append([]string{a.BinField()}, groupBy...)
+	}
+	if len(groupBy) == 0 {
+		return stats
+	}
+	return fmt.Sprintf("%s by %s", stats, strings.Join(groupBy, ", "))
+}
+
+func (a Aggregation) sort() string {
+	return fmt.Sprintf("| sort %s desc", a.SortBy)
+}
+
+// Merge merges aggregation results of several queries (e.g. the same query run on different batches of log groups),
+// rows with the same group by values are summed and results are sorted again by the sort metric (histogram results
+// are sorted by time)
+func (a Aggregation) Merge(results ...[]map[string]string) []map[string]string {
+	var keys []string
+	rowByKey := make(map[string]map[string]string)
+	for _, rows := range results {
+		for _, row := range rows {
+			key := a.groupKey(row)
+			if a.IsHistogram() {
+				key = row[a.BinField()] + "\x00" + key
+			}
+
+			merged, ok := rowByKey[key]
+			if !ok {
+				merged = make(map[string]string)
+				for _, field := range a.GroupBy {
+					merged[field] = row[field]
+				}
+				if a.IsHistogram() {
+					merged[a.BinField()] = row[a.BinField()]
+				}
+				keys = append(keys, key)
+				rowByKey[key] = merged
+			}
+			for _, field := range []string{RecordsField, PacketsField, BytesField} {
+				merged[field] = strconv.FormatInt(ToInt64(merged[field])+ToInt64(row[field]), 10)
+			}
+		}
+	}
+
+	var out []map[string]string
+	for _, key := range keys {
+		out = append(out, rowByKey[key])
+	}
+	if a.IsHistogram() {
+		slices.SortStableFunc(out, func(x, y map[string]string) int {
+			return cmp.Or(strings.Compare(x[a.BinField()], y[a.BinField()]), strings.Compare(a.groupKey(x), a.groupKey(y)))
+		})
+		return out
+	}
+	slices.SortStableFunc(out, func(x, y map[string]string) int {
+		return cmp.Compare(ToInt64(y[a.SortBy]), ToInt64(x[a.SortBy]))
+	})
+	return out
+}
+
+// groupKey returns group by values of the row as a single key
+func (a Aggregation) groupKey(row map[string]string) string {
+	var values []string
+	for _, field := range a.GroupBy {
+		values = append(values, row[field])
+	}
+	return strings.Join(values, "\x00")
+}
+
+// ToInt64 parses integer field value (e.g. bytes, stats sums), 0 is returned for missing or non-numeric value
+func ToInt64(in string) int64 {
+	if out, err := strconv.ParseInt(in, 10, 64); err == nil {
+		return out
+	}
+	// large sums can be returned in float format
+	if out, err := strconv.ParseFloat(in, 64); err == nil {
+		return int64(out)
+	}
+	return 0
+}
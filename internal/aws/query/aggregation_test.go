@@ -0,0 +1,111 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewAggregation(t *testing.T) {
+	tests := []struct {
+		name        string
+		groupBy     []string
+		sortBy      string
+		wantGroupBy []string
+		wantSortBy  string
+	}{
+		{"default sort by bytes", []string{"src-addr"}, "", []string{"srcAddr"}, BytesField},
+		{"packets", []string{"dst-addr"}, "packets", []string{"dstAddr"}, PacketsField},
+		{"count", []string{"eni"}, "count", []string{"interfaceId"}, RecordsField},
+		{"port is destination port", []string{"port"}, "bytes", []string{"dstPort"}, BytesField},
+		{"multiple keys", []string{"src-addr", "dst-port"}, "bytes", []string{"srcAddr", "dstPort"}, BytesField},
+		{"duplicate fields", []string{"port", "dst-port"}, "bytes", []string{"dstPort"}, BytesField},
+		{"case insensitive", []string{"Protocol"}, "COUNT", []string{"protocol"}, RecordsField},
+		{"no group by", nil, "bytes", nil, BytesField},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewAggregation(tc.groupBy, tc.sortBy)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got.GroupBy, tc.wantGroupBy) {
+				t.Errorf("group by: got %v, want %v", got.GroupBy, tc.wantGroupBy)
+			}
+			if got.SortBy != tc.wantSortBy {
+				t.Errorf("sort by: got %q, want %q", got.SortBy, tc.wantSortBy)
+			}
+		})
+	}
+}
+
+func TestNewAggregationInvalid(t *testing.T) {
+	if _, err := NewAggregation([]string{"not-a-key"}, "bytes"); err == nil {
+		t.Error("expected error for invalid key")
+	}
+	if _, err := NewAggregation([]string{"src-addr"}, "not-a-metric"); err == nil {
+		t.Error("expected error for invalid metric")
+	}
+}
+
+func TestQueryStats(t *testing.T) {
+	aggregation, err := NewAggregation([]string{"src-addr", "dst-port"}, "packets")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	q := NewQuery(100, 60).NoNoData().Stats(aggregation)
+	lines := strings.Split(q.GetQuery(), "\n")
+	wantTail := []string{
+		"| stats count(*) as records, sum(packets) as totalPackets, sum(bytes) as totalBytes by srcAddr, dstPort",
+		"| sort totalPackets desc",
+	}
+	if got := lines[len(lines)-2:]; !reflect.DeepEqual(got, wantTail) {
+		t.Errorf("query tail\n  got:  %q\n  want: %q", got, wantTail)
+	}
+
+	got, ok := q.GetAggregation()
+	if !ok || !reflect.DeepEqual(got, aggregation) {
+		t.Errorf("GetAggregation() = %v, %v", got, ok)
+	}
+	if _, ok := NewQuery(100, 60).Sort().GetAggregation(); ok {
+		t.Error("query without stats should not have aggregation")
+	}
+}
+
+func TestQueryStatsWithoutGroupBy(t *testing.T) {
+	aggregation, err := NewAggregation(nil, "count")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "| stats count(*) as records, sum(packets) as totalPackets, sum(bytes) as totalBytes\n| sort records desc"
+	if got := NewQuery(100, 60).Stats(aggregation).GetQuery(); !strings.HasSuffix(got, want) {
+		t.Errorf("query\n  got:  %q\n  want suffix: %q", got, want)
+	}
+}
+
+func TestAggregationMerge(t *testing.T) {
+	a, err := NewAggregation([]string{"src-addr"}, "bytes")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	batchA := []map[string]string{
+		{"srcAddr": "10.0.0.1", RecordsField: "2", PacketsField: "20", BytesField: "200"},
+		{"srcAddr": "10.0.0.2", RecordsField: "1", PacketsField: "10", BytesField: "100"},
+	}
+	batchB := []map[string]string{
+		{"srcAddr": "10.0.0.2", RecordsField: "3", PacketsField: "30", BytesField: "1.5e3"},
+		{"srcAddr": "10.0.0.3", RecordsField: "1", PacketsField: "1", BytesField: "50"},
+	}
+
+	got := a.Merge(batchA, batchB)
+	want := []map[string]string{
+		{"srcAddr": "10.0.0.2", RecordsField: "4", PacketsField: "40", BytesField: "1600"},
+		{"srcAddr": "10.0.0.1", RecordsField: "2", PacketsField: "20", BytesField: "200"},
+		{"srcAddr": "10.0.0.3", RecordsField: "1", PacketsField: "1", BytesField: "50"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,179 @@
+package query
+
+import (
+	"cmp"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+)
+
+// Endpoint is address and port of one side of a conversation
+type Endpoint struct {
+	Addr string
+	Port string
+}
+
+func (e Endpoint) String() string {
+	if a, err := netip.ParseAddr(e.Addr); err == nil && a.Is6() {
+		return fmt.Sprintf("[%s]:%s", e.Addr, e.Port)
+	}
+	return fmt.Sprintf("%s:%s", e.Addr, e.Port)
+}
+
+// Hop is conversation traffic recorded by a single network interface. NAT gateway interface records the conversation
+// twice, before (client and NAT) and after (NAT and server) translation, NAT is true for the latter.
+type Hop struct {
+	InterfaceId string
+	NAT         bool
+	// Records is number of flow log records of the conversation on the interface
+	Records int
+	// BytesOut and PacketsOut are sent by client to server, BytesIn and PacketsIn are sent by server to client
+	BytesOut   int64
+	BytesIn    int64
+	PacketsOut int64
+	PacketsIn  int64
+}
+
+// Conversation is end-to-end traffic between client and server (protocol and both endpoints), joined across network
+// interfaces and NAT. Client is the endpoint with higher (ephemeral) port.
+type Conversation struct {
+	Protocol string
+	Client   Endpoint
+	Server   Endpoint
+	Accepted int
+	Rejected int
+	Hops     []Hop
+}
+
+// BytesOut returns bytes sent by client, as recorded by the hop that recorded the most bytes
+func (c Conversation) BytesOut() int64 {
+	var out int64
+	for _, h := range c.Hops {
+		out = max(out, h.BytesOut)
+	}
+	return out
+}
+
+// BytesIn returns bytes sent by server, as recorded by the hop that recorded the most bytes
+func (c Conversation) BytesIn() int64 {
+	var out int64
+	for _, h := range c.Hops {
+		out = max(out, h.BytesIn)
+	}
+	return out
+}
+
+// natKey identifies translated (post-NAT) side of a connection, NAT address and port talking to the remote endpoint
+type natKey struct {
+	protocol string
+	nat      Endpoint
+	remote   Endpoint
+}
+
+// Conversations joins flow log records (keyed by query field names) to conversations. Records of the same connection
+// (protocol and both endpoints, in either direction) on different network interfaces are the same conversation.
+// Packet addresses (pktSrcAddr, pktDstAddr) are used to find the original endpoints of traffic that passes through
+// NAT gateway, so pre-NAT and post-NAT legs are joined as well. Legs are joined only if NAT gateway keeps the client
+// source port, flow logs do not record translated port, so legs of port translated connections are separate
+// conversations. Conversations are sorted by bytes, largest first.
+func Conversations(rows []map[string]string) []Conversation {
+	// records where packet address differs from the address (NAT gateway interface) map NAT address to the original
+	// address, the NAT address is then replaced in the post-NAT records
+	translations := make(map[natKey]string)
+	for _, row := range rows {
+		src, dst := Endpoint{Addr: row["srcAddr"], Port: row["srcPort"]}, Endpoint{Addr: row["dstAddr"], Port: row["dstPort"]}
+		pktSrc, pktDst := packetAddr(row["pktSrcAddr"], src.Addr), packetAddr(row["pktDstAddr"], dst.Addr)
+		// NAT translates source address, source port is assumed to be kept (see Conversations)
+		if pktDst != dst.Addr {
+			// client -> NAT, original destination is in the packet
+			translations[natKey{protocol: row["protocol"], nat: Endpoint{Addr: dst.Addr, Port: src.Port}, remote: Endpoint{Addr: pktDst, Port: dst.Port}}] = src.Addr
+		}
+		if pktSrc != src.Addr {
+			// NAT -> client, original source is in the packet
+			translations[natKey{protocol: row["protocol"], nat: Endpoint{Addr: src.Addr, Port: dst.Port}, remote: Endpoint{Addr: pktSrc, Port: src.Port}}] = dst.Addr
+		}
+	}
+
+	var keys []string
+	conversationByKey := make(map[string]*Conversation)
+	hopIndex := make(map[string]int)
+	for _, row := range rows {
+		if row["srcAddr"] == "" || row["srcAddr"] == "-" {
+			continue
+		}
+		src := Endpoint{Addr: packetAddr(row["pktSrcAddr"], row["srcAddr"]), Port: row["srcPort"]}
+		dst := Endpoint{Addr: packetAddr(row["pktDstAddr"], row["dstAddr"]), Port: row["dstPort"]}
+		var translated bool
+		if addr, ok := translations[natKey{protocol: row["protocol"], nat: src, remote: dst}]; ok {
+			src.Addr, translated = addr, true
+		}
+		if addr, ok := translations[natKey{protocol: row["protocol"], nat: dst, remote: src}]; ok {
+			dst.Addr, translated = addr, true
+		}
+
+		client, server := src, dst
+		if isServer(src, dst) {
+			client, server = dst, src
+		}
+		key := fmt.Sprintf("%s %s %s", row["protocol"], client, server)
+		c, ok := conversationByKey[key]
+		if !ok {
+			c = &Conversation{Protocol: row["protocol"], Client: client, Server: server}
+			conversationByKey[key] = c
+			keys = append(keys, key)
+		}
+		switch row["action"] {
+		case "ACCEPT":
+			c.Accepted++
+		case "REJECT":
+			c.Rejected++
+		}
+
+		hopKey := fmt.Sprintf("%s %s %t", key, row["interfaceId"], translated)
+		i, ok := hopIndex[hopKey]
+		if !ok {
+			i = len(c.Hops)
+			hopIndex[hopKey] = i
+			c.Hops = append(c.Hops, Hop{InterfaceId: row["interfaceId"], NAT: translated})
+		}
+		hop := &c.Hops[i]
+		hop.Records++
+		bytes, packets := ToInt64(row["bytes"]), ToInt64(row["packets"])
+		if src == client {
+			hop.BytesOut += bytes
+			hop.PacketsOut += packets
+		} else {
+			hop.BytesIn += bytes
+			hop.PacketsIn += packets
+		}
+	}
+
+	var out []Conversation
+	for _, key := range keys {
+		out = append(out, *conversationByKey[key])
+	}
+	slices.SortStableFunc(out, func(x, y Conversation) int {
+		return cmp.Compare(y.BytesOut()+y.BytesIn(), x.BytesOut()+x.BytesIn())
+	})
+	return out
+}
+
+// packetAddr returns packet address, or the address if the packet address is not set
+func packetAddr(pktAddr, addr string) string {
+	if pktAddr == "" || pktAddr == "-" {
+		return addr
+	}
+	return pktAddr
+}
+
+// isServer returns true if a is server side of the conversation with b - lower port (well-known port is lower than
+// ephemeral port), or lower address if the ports are the same
+func isServer(a, b Endpoint) bool {
+	aPort, aErr := strconv.Atoi(a.Port)
+	bPort, bErr := strconv.Atoi(b.Port)
+	if aErr == nil && bErr == nil && aPort != bPort {
+		return aPort < bPort
+	}
+	return a.Addr < b.Addr
+}
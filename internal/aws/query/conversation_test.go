@@ -0,0 +1,59 @@
+package query
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestConversationsThroughNat(t *testing.T) {
+	flow := func(eni, src, dst, srcPort, dstPort, pktSrc, pktDst, bytes, direction string) map[string]string {
+		return map[string]string{
+			"interfaceId": eni, "srcAddr": src, "dstAddr": dst, "srcPort": srcPort, "dstPort": dstPort,
+			"pktSrcAddr": pktSrc, "pktDstAddr": pktDst, "protocol": "6", "packets": "1", "bytes": bytes,
+			"action": "ACCEPT", "flowDirection": direction,
+		}
+	}
+	// instance 10.0.1.5 talks to 203.0.113.5:443 through NAT gateway 10.0.0.220
+	rows := []map[string]string{
+		flow("eni-instance", "10.0.1.5", "203.0.113.5", "40000", "443", "10.0.1.5", "203.0.113.5", "100", "egress"),
+		flow("eni-instance", "203.0.113.5", "10.0.1.5", "443", "40000", "203.0.113.5", "10.0.1.5", "900", "ingress"),
+		flow("eni-nat", "10.0.1.5", "10.0.0.220", "40000", "443", "10.0.1.5", "203.0.113.5", "100", "ingress"),
+		flow("eni-nat", "10.0.0.220", "203.0.113.5", "40000", "443", "10.0.0.220", "203.0.113.5", "100", "egress"),
+		flow("eni-nat", "203.0.113.5", "10.0.0.220", "443", "40000", "203.0.113.5", "10.0.0.220", "900", "ingress"),
+		flow("eni-nat", "10.0.0.220", "10.0.1.5", "443", "40000", "203.0.113.5", "10.0.1.5", "900", "egress"),
+		// unrelated conversation, ports are the same, so lower address is server
+		flow("eni-instance", "10.0.1.5", "10.0.2.2", "123", "123", "-", "-", "76", "egress"),
+		// no data records are skipped
+		{"interfaceId": "eni-instance", "srcAddr": "-", "logStatus": "NODATA"},
+	}
+
+	got := Conversations(rows)
+	if len(got) != 2 {
+		t.Fatalf("Conversations() returned %d conversations, want 2: %+v", len(got), got)
+	}
+
+	c := got[0]
+	if c.Client.String() != "10.0.1.5:40000" || c.Server.String() != "203.0.113.5:443" || c.Protocol != "6" {
+		t.Errorf("conversation = %s %s -> %s", c.Protocol, c.Client, c.Server)
+	}
+	wantHops := "[{eni-instance false 2 100 900 1 1} {eni-nat false 2 100 900 1 1} {eni-nat true 2 100 900 1 1}]"
+	if hops := fmt.Sprint(c.Hops); hops != wantHops {
+		t.Errorf("hops = %s, want %s", hops, wantHops)
+	}
+	if c.Accepted != 6 || c.Rejected != 0 {
+		t.Errorf("accepted %d, rejected %d", c.Accepted, c.Rejected)
+	}
+	if c.BytesOut() != 100 || c.BytesIn() != 900 {
+		t.Errorf("bytes out %d, in %d", c.BytesOut(), c.BytesIn())
+	}
+
+	if c := got[1]; c.Client.String() != "10.0.2.2:123" || c.Server.String() != "10.0.1.5:123" {
+		t.Errorf("conversation = %s -> %s", c.Client, c.Server)
+	}
+}
+
+func TestEndpointString(t *testing.T) {
+	if got := (Endpoint{Addr: "2001:db8::1", Port: "443"}).String(); got != "[2001:db8::1]:443" {
+		t.Errorf("String() = %q", got)
+	}
+}
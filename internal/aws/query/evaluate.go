@@ -0,0 +1,88 @@
+package query
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Evaluate runs the query pipeline over flow log records (keyed by query field names) with the same semantics as Logs
+// Insights - records are filtered, sorted by @timestamp (newest first) or aggregated (including histogram time
+// buckets), limited (unless the query is complete) and only the query fields are returned. Time range is not part of
+// the pipeline, records are expected to be selected by the caller. Number of records matched by the filters (before
+// aggregation and limit, same as Logs Insights records matched statistic) is returned with the results. Queries with
+// raw Logs Insights pipeline cannot be evaluated.
+func (q Query) Evaluate(records []map[string]string) ([]map[string]string, int, error) {
+	if err := q.Err(); err != nil {
+		return nil, 0, err
+	}
+	if q.raw {
+		return nil, 0, errors.New("query with raw Logs Insights pipeline cannot be evaluated")
+	}
+
+	var out []map[string]string
+	for _, record := range records {
+		if q.Match(record) {
+			out = append(out, q.project(record))
+		}
+	}
+	matched := len(out)
+
+	if q.aggregation != nil {
+		out = q.aggregation.aggregate(out)
+	} else if q.HasStage("sort") {
+		slices.SortStableFunc(out, func(x, y map[string]string) int {
+			return strings.Compare(y["@timestamp"], x["@timestamp"])
+		})
+	}
+
+	if !q.complete && q.limit > 0 && len(out) > q.limit {
+		out = out[:q.limit]
+	}
+	return out, matched, nil
+}
+
+// project returns record with fields selected by the query, fields missing in the record are not returned (same as
+// Logs Insights). @ptr is always returned, it identifies the record.
+func (q Query) project(record map[string]string) map[string]string {
+	fields, ok := q.stages[0].(fieldsStage)
+	if !ok {
+		return record
+	}
+	out := make(map[string]string)
+	for _, field := range append([]string{"@ptr"}, fields...) {
+		if v, ok := record[field]; ok {
+			out[field] = v
+		}
+	}
+	return out
+}
+
+// aggregate groups records by the group by fields, counts records and sums packets and bytes. Records with missing
+// (or non-numeric) packets and bytes are counted, but not summed. Histogram skips records without valid @timestamp
+// (e.g. exported records without start field), they do not belong to any time bucket.
+func (a Aggregation) aggregate(records []map[string]string) []map[string]string {
+	var rows []map[string]string
+	for _, record := range records {
+		var bin string
+		if a.IsHistogram() {
+			if bin = a.bin(record["@timestamp"]); bin == "" {
+				continue
+			}
+		}
+		row := map[string]string{
+			RecordsField: "1",
+			PacketsField: strconv.FormatInt(ToInt64(record["packets"]), 10),
+			BytesField:   strconv.FormatInt(ToInt64(record["bytes"]), 10),
+		}
+		for _, field := range a.GroupBy {
+			row[field] = record[field]
+		}
+		if a.IsHistogram() {
+			row[a.BinField()] = bin
+		}
+		rows = append(rows, row)
+	}
+	return a.Merge(rows)
+}
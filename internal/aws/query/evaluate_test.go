@@ -0,0 +1,432 @@
+package query
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// records is flow log fixture covering ingress and egress, IPv4 and IPv6, accepted and rejected traffic
+var records = []map[string]string{
+	{"@ptr": "1", "@timestamp": "2024-05-01 10:00:01.000", "@log": "a:vpc", "interfaceId": "eni-1", "srcAddr": "203.0.113.7", "dstAddr": "10.0.1.5", "srcPort": "51000", "dstPort": "22", "protocol": "6", "packets": "10", "bytes": "840", "action": "REJECT", "logStatus": "OK", "flowDirection": "ingress", "tcpFlags": "2", "type": "IPv4"},
+	{"@ptr": "2", "@timestamp": "2024-05-01 10:00:05.000", "@log": "a:vpc", "interfaceId": "eni-1", "srcAddr": "10.0.1.5", "dstAddr": "10.0.2.9", "srcPort": "40000", "dstPort": "443", "protocol": "6", "packets": "20", "bytes": "4000", "action": "ACCEPT", "logStatus": "OK", "flowDirection": "egress", "tcpFlags": "19", "type": "IPv4"},
+	{"@ptr": "3", "@timestamp": "2024-05-01 10:00:03.000", "@log": "a:vpc", "interfaceId": "eni-2", "srcAddr": "10.0.2.9", "dstAddr": "10.0.1.5", "srcPort": "443", "dstPort": "40000", "protocol": "6", "packets": "18", "bytes": "9000", "action": "ACCEPT", "logStatus": "OK", "flowDirection": "ingress", "tcpFlags": "18", "type": "IPv4"},
+	{"@ptr": "4", "@timestamp": "2024-05-01 10:00:02.000", "@log": "b:vpc", "interfaceId": "eni-3", "srcAddr": "10.0.3.3", "dstAddr": "169.254.169.123", "srcPort": "123", "dstPort": "123", "protocol": "17", "packets": "1", "bytes": "76", "action": "ACCEPT", "logStatus": "OK", "flowDirection": "egress", "type": "IPv4"},
+	{"@ptr": "5", "@timestamp": "2024-05-01 10:00:04.000", "@log": "b:vpc", "interfaceId": "eni-3", "srcAddr": "2001:db8::1", "dstAddr": "2001:db8:1::5", "srcPort": "3389", "dstPort": "50123", "protocol": "6", "packets": "4", "bytes": "320", "action": "REJECT", "logStatus": "OK", "flowDirection": "egress", "tcpFlags": "4", "type": "IPv6", "pktDstAwsService": "S3"},
+	{"@ptr": "6", "@timestamp": "2024-05-01 10:00:00.000", "@log": "b:vpc", "interfaceId": "eni-4", "srcAddr": "-", "dstAddr": "-", "srcPort": "-", "dstPort": "-", "protocol": "-", "packets": "-", "bytes": "-", "action": "-", "logStatus": "NODATA"},
+	{"@ptr": "7", "@timestamp": "2024-05-01 10:00:06.000", "@log": "a:vpc", "interfaceId": "eni-1", "srcAddr": "198.51.100.1", "dstAddr": "10.0.1.5", "srcPort": "1024", "dstPort": "3389", "protocol": "6", "packets": "3", "bytes": "180", "action": "REJECT", "logStatus": "OK", "flowDirection": "ingress", "tcpFlags": "2", "type": "IPv4", "instanceId": `i-"1\`},
+}
+
+// TestEvaluateConformance checks that records matched by the evaluator are the same records that are matched by the
+// generated Logs Insights filters (interpreted by insightsFilter below)
+func TestEvaluateConformance(t *testing.T) {
+	mustWhere := func(in string) Expr {
+		e, err := ParseWhere(in)
+		if err != nil {
+			t.Fatalf("ParseWhere(%q) unexpected error: %v", in, err)
+		}
+		return e
+	}
+	tcpFlags, err := ParseTcpFlags("SYN,!ACK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ports, err := ParsePorts("22,1000-2000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := NewQuery(0, 60).NoNoData().NoSkipData()
+	tests := []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{"no filters", base, []string{"1", "2", "3", "4", "5", "7"}},
+		{"reject", base.Reject(), []string{"1", "5", "7"}},
+		{"ingress accept", base.Ingress().Accept(), []string{"3"}},
+		{"ports", base.Ports(ports), []string{"1", "7"}},
+		{"destination port", base.DestinationPort(443), []string{"2"}},
+		{"protocols", base.Protocols("udp"), []string{"4"}},
+		{"not protocols", base.NotProtocols("tcp"), []string{"4"}},
+		{"address cidr", base.Address("10.0.2.0/24"), []string{"2", "3"}},
+		{"ipv6 address cidr", base.SourceAddress("2001:db8::/32"), []string{"5"}},
+		{"not address", base.NotAddress("10.0.0.0/8"), []string{"5"}},
+		{"not ports", base.NotPorts(Ports{{From: 0, To: 1023}}), []string{"5", "7"}},
+		{"tcp flags", base.TcpFlags(tcpFlags), []string{"1", "7"}},
+		{"aws service", base.AwsService("s3"), []string{"5"}},
+		{"traffic type", base.TrafficType("IPv6"), []string{"5"}},
+		{"escaped literal", base.InstanceId(`i-"1\`), []string{"7"}},
+		{"where", base.Where(mustWhere(`dstPort in (22,3389) and (srcAddr in 0.0.0.0/0 and not srcAddr in 10.0.0.0/8) or action = REJECT`)), []string{"1", "5", "7"}},
+		{"where numbers", base.Where(mustWhere(`bytes >= 320 and packets < 18`)), []string{"1", "5"}},
+		{"where remote port", base.Where(mustWhere(`remote-port = 443`)), []string{"2", "3"}},
+		{"where ni addr", base.Where(mustWhere(`ni-addr = 10.0.1.5 and direction != egress`)), []string{"1", "3", "7"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			results, _, err := tc.q.Complete().Evaluate(records)
+			if err != nil {
+				t.Fatalf("Evaluate() unexpected error: %v", err)
+			}
+			got := ptrs(results)
+			if !slicesEqualUnordered(got, tc.want) {
+				t.Errorf("Evaluate() = %v, want %v", got, tc.want)
+			}
+
+			var insights []string
+			for _, record := range records {
+				ok, err := insightsFilter(tc.q.GetQuery(), record)
+				if err != nil {
+					t.Fatalf("insights filter %q: %v", tc.q.GetQuery(), err)
+				}
+				if ok {
+					insights = append(insights, record["@ptr"])
+				}
+			}
+			if !slicesEqualUnordered(insights, got) {
+				t.Errorf("Logs Insights filters matched %v, evaluator matched %v\n%s", insights, got, tc.q.GetQuery())
+			}
+		})
+	}
+}
+
+func TestEvaluateSortLimit(t *testing.T) {
+	results, matched, err := NewQuery(3, 60).NoNoData().Sort().Evaluate(records)
+	if err != nil {
+		t.Fatalf("Evaluate() unexpected error: %v", err)
+	}
+	// matched records are counted before the limit is applied
+	if matched != 6 {
+		t.Errorf("Evaluate() matched = %d, want 6", matched)
+	}
+	if got, want := ptrs(results), []string{"7", "2", "5"}; !slicesEqual(got, want) {
+		t.Errorf("Evaluate() = %v, want %v", got, want)
+	}
+	if _, ok := results[0]["logStatus"]; ok {
+		t.Error("Evaluate() returned field that is not selected by the query")
+	}
+	if results[0]["instanceId"] != `i-"1\` {
+		t.Errorf("Evaluate() instanceId = %q", results[0]["instanceId"])
+	}
+
+	results, _, err = NewQuery(3, 60).Complete().Sort().Evaluate(records)
+	if err != nil {
+		t.Fatalf("Evaluate() unexpected error: %v", err)
+	}
+	if len(results) != len(records) {
+		t.Errorf("Evaluate() complete query returned %d records, want %d", len(results), len(records))
+	}
+}
+
+func TestEvaluateAggregation(t *testing.T) {
+	aggregation, err := NewAggregation([]string{"action"}, "bytes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, _, err := NewQuery(100, 60).NoNoData().Stats(aggregation).Evaluate(records)
+	if err != nil {
+		t.Fatalf("Evaluate() unexpected error: %v", err)
+	}
+	want := []map[string]string{
+		{"action": "ACCEPT", RecordsField: "3", PacketsField: "39", BytesField: "13076"},
+		{"action": "REJECT", RecordsField: "3", PacketsField: "17", BytesField: "1340"},
+	}
+	if fmt.Sprint(results) != fmt.Sprint(want) {
+		t.Errorf("Evaluate() = %v, want %v", results, want)
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	if _, _, err := NewQuery(10, 60).Insights("| dedup srcAddr").Evaluate(records); err == nil {
+		t.Error("Evaluate() expected error for raw pipeline")
+	}
+	if _, _, err := NewQuery(10, 60).Address("10.0.0.300").Evaluate(records); err == nil {
+		t.Error("Evaluate() expected error for invalid query")
+	}
+}
+
+func ptrs(rows []map[string]string) []string {
+	var out []string
+	for _, row := range rows {
+		out = append(out, row["@ptr"])
+	}
+	return out
+}
+
+func slicesEqual(a, b []string) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func slicesEqualUnordered(a, b []string) bool {
+	count := make(map[string]int)
+	for _, v := range a {
+		count[v]++
+	}
+	for _, v := range b {
+		count[v]--
+	}
+	for _, v := range count {
+		if v != 0 {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+// insightsFilter interprets '| filter' stages of Logs Insights query text (the subset of syntax generated by Expr)
+// independently of the query package (tokenizer, comparisons and literal rules are implemented here) and returns true
+// if the record matches all of them
+func insightsFilter(query string, record map[string]string) (bool, error) {
+	for _, line := range strings.Split(query, "\n") {
+		filter, ok := strings.CutPrefix(line, "| filter ")
+		if !ok {
+			continue
+		}
+		tokens, err := insightsTokens(filter)
+		if err != nil {
+			return false, err
+		}
+		p := &insightsParser{tokens: tokens, record: record}
+		match, err := p.or()
+		if err != nil {
+			return false, err
+		}
+		if t := p.next(); t != (insightsToken{}) {
+			return false, fmt.Errorf("unexpected %q", t.text)
+		}
+		if !match {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// insightsToken is token of Logs Insights filter, quoted is true for string literals
+type insightsToken struct {
+	text   string
+	quoted bool
+}
+
+// insightsTokens splits Logs Insights filter to words, string literals (with \" and \\ escapes), operators and
+// punctuation
+func insightsTokens(in string) ([]insightsToken, error) {
+	var out []insightsToken
+	for i := 0; i < len(in); {
+		c := in[i]
+		switch {
+		case c == ' ':
+			i++
+		case c == '"':
+			var b strings.Builder
+			i++
+			for ; i < len(in) && in[i] != '"'; i++ {
+				if in[i] == '\\' {
+					i++
+					if i == len(in) {
+						return nil, fmt.Errorf("unterminated string in %q", in)
+					}
+				}
+				b.WriteByte(in[i])
+			}
+			if i == len(in) {
+				return nil, fmt.Errorf("unterminated string in %q", in)
+			}
+			i++
+			out = append(out, insightsToken{text: b.String(), quoted: true})
+		case strings.ContainsRune("()[],", rune(c)):
+			out = append(out, insightsToken{text: string(c)})
+			i++
+		case strings.ContainsRune("=!<>", rune(c)):
+			j := i + 1
+			if j < len(in) && in[j] == '=' {
+				j++
+			}
+			out = append(out, insightsToken{text: in[i:j]})
+			i = j
+		default:
+			j := i
+			for j < len(in) && !strings.ContainsRune(" \"()[],=!<>", rune(in[j])) {
+				j++
+			}
+			out = append(out, insightsToken{text: in[i:j]})
+			i = j
+		}
+	}
+	return out, nil
+}
+
+type insightsParser struct {
+	tokens []insightsToken
+	pos    int
+	record map[string]string
+}
+
+func (p *insightsParser) peek() insightsToken {
+	if p.pos == len(p.tokens) {
+		return insightsToken{}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *insightsParser) next() insightsToken {
+	t := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return t
+}
+
+func (p *insightsParser) expect(text string) error {
+	if t := p.next(); t.quoted || t.text != text {
+		return fmt.Errorf("unexpected %q, expected %q", t.text, text)
+	}
+	return nil
+}
+
+// isKeyword returns true if the next token is unquoted keyword
+func (p *insightsParser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return !t.quoted && t.text == keyword
+}
+
+func (p *insightsParser) or() (bool, error) {
+	out, err := p.and()
+	for err == nil && p.isKeyword("or") {
+		p.next()
+		var v bool
+		v, err = p.and()
+		out = out || v
+	}
+	return out, err
+}
+
+func (p *insightsParser) and() (bool, error) {
+	out, err := p.unary()
+	for err == nil && p.isKeyword("and") {
+		p.next()
+		var v bool
+		v, err = p.unary()
+		out = out && v
+	}
+	return out, err
+}
+
+func (p *insightsParser) unary() (bool, error) {
+	if p.isKeyword("not") {
+		p.next()
+		v, err := p.unary()
+		return !v, err
+	}
+	t := p.next()
+	switch {
+	case t.quoted || t.text == "":
+	case t.text == "(":
+		v, err := p.or()
+		if err != nil {
+			return false, err
+		}
+		return v, p.expect(")")
+	case t.text == "isIpv4InSubnet" || t.text == "isIpInSubnet":
+		return p.subnet(t.text == "isIpv4InSubnet")
+	default:
+		return p.comparison(t.text)
+	}
+	return false, fmt.Errorf("unexpected %q", t.text)
+}
+
+func (p *insightsParser) subnet(ipv4 bool) (bool, error) {
+	if err := p.expect("("); err != nil {
+		return false, err
+	}
+	field := p.next()
+	if err := p.expect(","); err != nil {
+		return false, err
+	}
+	cidr := p.next()
+	if err := p.expect(")"); err != nil {
+		return false, err
+	}
+	prefix, err := netip.ParsePrefix(cidr.text)
+	if err != nil || !cidr.quoted {
+		return false, fmt.Errorf("invalid subnet %q", cidr.text)
+	}
+	addr, err := netip.ParseAddr(p.record[field.text])
+	if err != nil || ipv4 && !addr.Is4() {
+		return false, nil
+	}
+	return prefix.Contains(addr), nil
+}
+
+func (p *insightsParser) comparison(field string) (bool, error) {
+	if p.isKeyword("in") {
+		p.next()
+		if err := p.expect("["); err != nil {
+			return false, err
+		}
+		var out bool
+		for {
+			v, err := p.literal("==", field)
+			if err != nil {
+				return false, err
+			}
+			out = out || v
+			switch t := p.next(); t.text {
+			case "]":
+				return out, nil
+			case ",":
+			default:
+				return false, fmt.Errorf("unexpected %q", t.text)
+			}
+		}
+	}
+	op := p.next()
+	switch op.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return p.literal(op.text, field)
+	}
+	return false, fmt.Errorf("unexpected %q, expected operator", op.text)
+}
+
+// literal compares field with the next literal, quoted literals are strings and compared as strings, unquoted are
+// numbers. Field value that is missing or not a number (e.g. '-' in NODATA records) is not equal to any number and
+// is neither less nor greater than a number.
+func (p *insightsParser) literal(op, field string) (bool, error) {
+	t := p.next()
+	value, ok := p.record[field]
+	if t.quoted {
+		switch op {
+		case "==":
+			return ok && value == t.text, nil
+		case "!=":
+			return !ok || value != t.text, nil
+		}
+		return false, fmt.Errorf("string literal %q compared with %s", t.text, op)
+	}
+
+	n, err := strconv.ParseFloat(t.text, 64)
+	if err != nil {
+		return false, fmt.Errorf("invalid number %q", t.text)
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return op == "!=", nil
+	}
+	switch op {
+	case "==":
+		return v == n, nil
+	case "!=":
+		return v != n, nil
+	case "<":
+		return v < n, nil
+	case "<=":
+		return v <= n, nil
+	case ">":
+		return v > n, nil
+	case ">=":
+		return v >= n, nil
+	}
+	return false, fmt.Errorf("invalid operator %q", op)
+}
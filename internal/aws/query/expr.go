@@ -0,0 +1,354 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Expr is typed filter expression. It is rendered to Logs Insights syntax (literals are quoted and escaped) and it is
+// matched client-side against flow log records, when flow logs are not queried by Logs Insights (e.g. live tail).
+type Expr interface {
+	// Insights returns expression in Logs Insights syntax (without '| filter' prefix)
+	Insights() string
+	// Match returns true if the flow log record (keyed by query field names) matches the expression
+	Match(row map[string]string) bool
+	// validate returns error if the expression, or any of its sub-expressions, is invalid
+	validate() error
+}
+
+// Operator is comparison operator
+type Operator string
+
+const (
+	Eq Operator = "=="
+	Ne Operator = "!="
+	Lt Operator = "<"
+	Le Operator = "<="
+	Gt Operator = ">"
+	Ge Operator = ">="
+)
+
+var operators = []Operator{Eq, Ne, Lt, Le, Gt, Ge}
+
+// fieldName is Logs Insights field name, system fields are prefixed with '@'
+var fieldName = regexp.MustCompile(`^@?[A-Za-z][A-Za-z0-9_]*$`)
+
+// Value is literal value of expression, string values are quoted and escaped, numbers are rendered as they are
+type Value struct {
+	str    string
+	num    int
+	number bool
+}
+
+func StringValue(v string) Value {
+	return Value{str: v}
+}
+
+func NumberValue(v int) Value {
+	return Value{num: v, number: true}
+}
+
+func (v Value) IsNumber() bool {
+	return v.number
+}
+
+// String returns value as it is compared to flow log record fields
+func (v Value) String() string {
+	if v.number {
+		return strconv.Itoa(v.num)
+	}
+	return v.str
+}
+
+func (v Value) insights() string {
+	if v.number {
+		return strconv.Itoa(v.num)
+	}
+	return quote(v.str)
+}
+
+// quote returns double-quoted string literal, backslashes, quotes and control characters are escaped
+func quote(in string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range in {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func validateField(field string) error {
+	if !fieldName.MatchString(field) {
+		return fmt.Errorf("invalid field name %q", field)
+	}
+	return nil
+}
+
+// Comparison compares field with the value. Numeric values are compared as numbers, string values as strings
+type Comparison struct {
+	Field string
+	Op    Operator
+	Value Value
+}
+
+func Compare(field string, op Operator, value Value) Comparison {
+	return Comparison{Field: field, Op: op, Value: value}
+}
+
+func (c Comparison) Insights() string {
+	return fmt.Sprintf("%s %s %s", c.Field, c.Op, c.Value.insights())
+}
+
+func (c Comparison) Match(row map[string]string) bool {
+	if !c.Value.number {
+		return compare(c.Op, strings.Compare(row[c.Field], c.Value.str))
+	}
+	n, err := strconv.Atoi(row[c.Field])
+	if err != nil {
+		// field is not a number (e.g. '-'), it is not equal to any number
+		return c.Op == Ne
+	}
+	return compare(c.Op, n-c.Value.num)
+}
+
+func (c Comparison) validate() error {
+	if !slices.Contains(operators, c.Op) {
+		return fmt.Errorf("invalid operator %q", c.Op)
+	}
+	return validateField(c.Field)
+}
+
+// compare returns result of the operator for cmp result (negative, zero or positive)
+func compare(op Operator, cmp int) bool {
+	switch op {
+	case Eq:
+		return cmp == 0
+	case Ne:
+		return cmp != 0
+	case Lt:
+		return cmp < 0
+	case Le:
+		return cmp <= 0
+	case Gt:
+		return cmp > 0
+	case Ge:
+		return cmp >= 0
+	}
+	return false
+}
+
+// InList matches if the field is equal to any of the values
+type InList struct {
+	Field  string
+	Values []Value
+}
+
+func In(field string, values ...Value) InList {
+	return InList{Field: field, Values: values}
+}
+
+func (e InList) Insights() string {
+	var values []string
+	for _, v := range e.Values {
+		values = append(values, v.insights())
+	}
+	return fmt.Sprintf("%s in [%s]", e.Field, strings.Join(values, ", "))
+}
+
+func (e InList) Match(row map[string]string) bool {
+	for _, v := range e.Values {
+		if (Comparison{Field: e.Field, Op: Eq, Value: v}).Match(row) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e InList) validate() error {
+	if len(e.Values) == 0 {
+		return fmt.Errorf("field %s: empty list", e.Field)
+	}
+	return validateField(e.Field)
+}
+
+// InSubnet matches if the field is IP address in the subnet
+type InSubnet struct {
+	Field  string
+	Prefix netip.Prefix
+}
+
+// Subnet returns expression that matches addresses in the subnet, host bits of the prefix are masked
+func Subnet(field string, prefix netip.Prefix) InSubnet {
+	return InSubnet{Field: field, Prefix: prefix.Masked()}
+}
+
+func (e InSubnet) Insights() string {
+	if e.Prefix.Addr().Is4() {
+		return fmt.Sprintf("isIpv4InSubnet(%s, %s)", e.Field, quote(e.Prefix.String()))
+	}
+	return fmt.Sprintf("isIpInSubnet(%s, %s)", e.Field, quote(e.Prefix.String()))
+}
+
+func (e InSubnet) Match(row map[string]string) bool {
+	a, err := netip.ParseAddr(row[e.Field])
+	if err != nil {
+		return false
+	}
+	return e.Prefix.Contains(a)
+}
+
+func (e InSubnet) validate() error {
+	if !e.Prefix.IsValid() {
+		return fmt.Errorf("field %s: invalid subnet", e.Field)
+	}
+	return validateField(e.Field)
+}
+
+// AndExpr matches if all sub-expressions match
+type AndExpr []Expr
+
+// And returns expression that matches if all expressions match, nested and expressions are flattened
+func And(exprs ...Expr) AndExpr {
+	var out AndExpr
+	for _, e := range exprs {
+		if and, ok := e.(AndExpr); ok {
+			out = append(out, and...)
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func (e AndExpr) Insights() string {
+	var out []string
+	for _, v := range e {
+		// 'and' has higher precedence than 'or'
+		if or, ok := v.(OrExpr); ok && len(or) > 1 {
+			out = append(out, fmt.Sprintf("(%s)", v.Insights()))
+			continue
+		}
+		out = append(out, v.Insights())
+	}
+	return strings.Join(out, " and ")
+}
+
+func (e AndExpr) Match(row map[string]string) bool {
+	for _, v := range e {
+		if !v.Match(row) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e AndExpr) validate() error {
+	return validateAll("and", e)
+}
+
+// OrExpr matches if any of the sub-expressions matches
+type OrExpr []Expr
+
+// Or returns expression that matches if any of the expressions matches, nested or expressions are flattened
+func Or(exprs ...Expr) OrExpr {
+	var out OrExpr
+	for _, e := range exprs {
+		if or, ok := e.(OrExpr); ok {
+			out = append(out, or...)
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func (e OrExpr) Insights() string {
+	var out []string
+	for _, v := range e {
+		// keep ranges (and expressions) grouped, e.g. (dstPort >= 1024 and dstPort <= 65535)
+		if and, ok := v.(AndExpr); ok && len(and) > 1 {
+			out = append(out, fmt.Sprintf("(%s)", v.Insights()))
+			continue
+		}
+		out = append(out, v.Insights())
+	}
+	return strings.Join(out, " or ")
+}
+
+func (e OrExpr) Match(row map[string]string) bool {
+	for _, v := range e {
+		if v.Match(row) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e OrExpr) validate() error {
+	return validateAll("or", e)
+}
+
+// NotExpr negates the expression
+type NotExpr struct {
+	Expr Expr
+}
+
+func Not(e Expr) NotExpr {
+	return NotExpr{Expr: e}
+}
+
+func (e NotExpr) Insights() string {
+	return fmt.Sprintf("not (%s)", e.Expr.Insights())
+}
+
+func (e NotExpr) Match(row map[string]string) bool {
+	return !e.Expr.Match(row)
+}
+
+func (e NotExpr) validate() error {
+	if e.Expr == nil {
+		return errors.New("not: empty expression")
+	}
+	return e.Expr.validate()
+}
+
+func validateAll(name string, exprs []Expr) error {
+	if len(exprs) == 0 {
+		return fmt.Errorf("%s: empty expression", name)
+	}
+	var errs []error
+	for _, e := range exprs {
+		if e == nil {
+			errs = append(errs, fmt.Errorf("%s: empty expression", name))
+			continue
+		}
+		errs = append(errs, e.validate())
+	}
+	return errors.Join(errs...)
+}
+
+// Validate returns error if the expression is invalid (e.g. invalid field name or operator)
+func Validate(e Expr) error {
+	if e == nil {
+		return errors.New("empty expression")
+	}
+	return e.validate()
+}
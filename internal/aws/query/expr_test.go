@@ -0,0 +1,101 @@
+package query
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestExprInsights(t *testing.T) {
+	tests := []struct {
+		name string
+		e    Expr
+		want string
+	}{
+		{"string", Compare("action", Eq, StringValue("ACCEPT")), `action == "ACCEPT"`},
+		{"number", Compare("bytes", Gt, NumberValue(1000)), `bytes > 1000`},
+		{"escaped", Compare("subnetId", Eq, StringValue(`a"b\c`+"\n")), `subnetId == "a\"b\\c\n"`},
+		{"in", In("dstPort", NumberValue(22), NumberValue(80)), `dstPort in [22, 80]`},
+		{"in strings", In("action", StringValue("ACCEPT"), StringValue("REJECT")), `action in ["ACCEPT", "REJECT"]`},
+		{"subnet", Subnet("srcAddr", netip.MustParsePrefix("10.1.2.3/16")), `isIpv4InSubnet(srcAddr, "10.1.0.0/16")`},
+		{"ipv6 subnet", Subnet("srcAddr", netip.MustParsePrefix("2001:db8::/32")), `isIpInSubnet(srcAddr, "2001:db8::/32")`},
+		{"not", Not(Compare("action", Eq, StringValue("ACCEPT"))), `not (action == "ACCEPT")`},
+		{
+			"and of or",
+			And(Compare("action", Eq, StringValue("REJECT")), Or(Compare("dstPort", Eq, NumberValue(22)), Compare("dstPort", Eq, NumberValue(3389)))),
+			`action == "REJECT" and (dstPort == 22 or dstPort == 3389)`,
+		},
+		{
+			"or of and",
+			Or(Compare("action", Eq, StringValue("REJECT")), And(Compare("bytes", Ge, NumberValue(1)), Compare("bytes", Le, NumberValue(9)))),
+			`action == "REJECT" or (bytes >= 1 and bytes <= 9)`,
+		},
+		{"nested or flattened", Or(Or(Compare("a", Eq, NumberValue(1)), Compare("b", Eq, NumberValue(2))), Compare("c", Eq, NumberValue(3))), `a == 1 or b == 2 or c == 3`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Validate(tc.e); err != nil {
+				t.Fatalf("Validate() unexpected error: %v", err)
+			}
+			if got := tc.e.Insights(); got != tc.want {
+				t.Errorf("Insights() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExprMatch(t *testing.T) {
+	row := map[string]string{"action": "REJECT", "dstPort": "22", "bytes": "1200", "srcAddr": "10.1.2.3", "pktSrcAddr": "-"}
+
+	tests := []struct {
+		name string
+		e    Expr
+		want bool
+	}{
+		{"string equals", Compare("action", Eq, StringValue("REJECT")), true},
+		{"string not equals", Compare("action", Ne, StringValue("REJECT")), false},
+		{"number greater", Compare("bytes", Gt, NumberValue(1000)), true},
+		{"number compared as number", Compare("bytes", Lt, NumberValue(200)), false},
+		{"not a number", Compare("pktSrcAddr", Eq, NumberValue(0)), false},
+		{"not a number not equals", Compare("pktSrcAddr", Ne, NumberValue(0)), true},
+		{"in", In("dstPort", NumberValue(22), NumberValue(3389)), true},
+		{"not in", In("dstPort", NumberValue(80)), false},
+		{"subnet", Subnet("srcAddr", netip.MustParsePrefix("10.0.0.0/8")), true},
+		{"missing field subnet", Subnet("dstAddr", netip.MustParsePrefix("0.0.0.0/0")), false},
+		{"and", And(Compare("action", Eq, StringValue("REJECT")), Compare("dstPort", Eq, NumberValue(22))), true},
+		{"or", Or(Compare("action", Eq, StringValue("ACCEPT")), Compare("dstPort", Eq, NumberValue(22))), true},
+		{"not", Not(Compare("action", Eq, StringValue("REJECT"))), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.e.Match(row); got != tc.want {
+				t.Errorf("Match() = %v, want %v (%s)", got, tc.want, tc.e.Insights())
+			}
+		})
+	}
+}
+
+func TestExprValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		e    Expr
+	}{
+		{"nil", nil},
+		{"invalid field", Compare("src Addr", Eq, StringValue("x"))},
+		{"injected field", Compare(`action == "x" | stats count(*)`, Eq, StringValue("x"))},
+		{"invalid operator", Compare("action", Operator("=~"), StringValue("x"))},
+		{"empty in", In("dstPort")},
+		{"invalid subnet", InSubnet{Field: "srcAddr"}},
+		{"empty and", And()},
+		{"nested invalid", Or(Compare("action", Eq, StringValue("x")), Not(Compare("1action", Eq, StringValue("x"))))},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Validate(tc.e); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
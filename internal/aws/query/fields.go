@@ -23,6 +23,36 @@ var FlowLogFieldsV2V5 = FlowLogFields{
 	"pkt-src-aws-service", "pkt-dst-aws-service", "flow-direction", "traffic-path", // version 5 fields
 }
 
+// FlowLogFieldsDefault are fields of flow logs created with the default format (no custom log format)
+var FlowLogFieldsDefault = FlowLogFields{
+	"version", "account-id", "interface-id", "srcaddr", "dstaddr", "srcport", "dstport", "protocol", "packets", "bytes",
+	"start", "end", "action", "log-status",
+}
+
+// ParseLogFormat parses flow log format (e.g. '${version} ${srcaddr} ...') as returned by describe flow logs, empty
+// format is the default format
+func ParseLogFormat(logFormat string) FlowLogFields {
+	if strings.TrimSpace(logFormat) == "" {
+		return FlowLogFieldsDefault
+	}
+
+	var fields FlowLogFields
+	for _, v := range strings.Fields(logFormat) {
+		fields = append(fields, strings.TrimSuffix(strings.TrimPrefix(v, "${"), "}"))
+	}
+	return fields
+}
+
+// Contains returns true if the log format contains Logs Insights field (e.g. flowDirection)
+func (f FlowLogFields) Contains(field string) bool {
+	for _, v := range f {
+		if ToField(v) == field {
+			return true
+		}
+	}
+	return false
+}
+
 // FlowLogFieldsV7 V7 fields can only be created if there is at least one ECS cluster in VPC
 // this is another crazy half-baked product by AWS, what if we want to create ECS cluster after?
 var FlowLogFieldsV7 = FlowLogFields{
@@ -32,9 +62,109 @@ var FlowLogFieldsV7 = FlowLogFields{
 
 // Fields used when querying flow logs (unsurprisingly naming convention is different from the above fields)
 var Fields = []string{
-	"@timestamp", "interfaceId", "srcAddr", "dstAddr", "srcPort", "dstPort", "protocol", "packets", "bytes",
-	"action",
-	"tcpFlags", "pktSrcAddr", "pktDstAddr",
-	"flowDirection", "trafficPath",
+	"@timestamp", "@log", "interfaceId", "srcAddr", "dstAddr", "srcPort", "dstPort", "protocol", "packets", "bytes",
+	"start", "end", "action",
+	"vpcId", "subnetId", "instanceId", "tcpFlags", "type", "pktSrcAddr", "pktDstAddr",
+	"pktSrcAwsService", "pktDstAwsService", "flowDirection", "trafficPath",
 	"ecsServiceName",
 }
+
+// fieldByLogFormatField maps flow log format fields to Logs Insights fields
+var fieldByLogFormatField = map[string]string{
+	"version":                    "version",
+	"account-id":                 "accountId",
+	"interface-id":               "interfaceId",
+	"srcaddr":                    "srcAddr",
+	"dstaddr":                    "dstAddr",
+	"srcport":                    "srcPort",
+	"dstport":                    "dstPort",
+	"protocol":                   "protocol",
+	"packets":                    "packets",
+	"bytes":                      "bytes",
+	"start":                      "start",
+	"end":                        "end",
+	"action":                     "action",
+	"log-status":                 "logStatus",
+	"vpc-id":                     "vpcId",
+	"subnet-id":                  "subnetId",
+	"instance-id":                "instanceId",
+	"tcp-flags":                  "tcpFlags",
+	"type":                       "type",
+	"pkt-srcaddr":                "pktSrcAddr",
+	"pkt-dstaddr":                "pktDstAddr",
+	"region":                     "region",
+	"az-id":                      "azId",
+	"sublocation-type":           "sublocationType",
+	"sublocation-id":             "sublocationId",
+	"pkt-src-aws-service":        "pktSrcAwsService",
+	"pkt-dst-aws-service":        "pktDstAwsService",
+	"flow-direction":             "flowDirection",
+	"traffic-path":               "trafficPath",
+	"ecs-cluster-arn":            "ecsClusterArn",
+	"ecs-cluster-name":           "ecsClusterName",
+	"ecs-container-instance-arn": "ecsContainerInstanceArn",
+	"ecs-container-instance-id":  "ecsContainerInstanceId",
+	"ecs-container-id":           "ecsContainerId",
+	"ecs-second-container-id":    "ecsSecondContainerId",
+	"ecs-service-name":           "ecsServiceName",
+	"ecs-task-definition-arn":    "ecsTaskDefinitionArn",
+	"ecs-task-arn":               "ecsTaskArn",
+	"ecs-task-id":                "ecsTaskId",
+	"reject-reason":              "rejectReason",
+}
+
+// ToField returns Logs Insights field name for flow log format field (e.g. pkt-srcaddr -> pktSrcAddr)
+func ToField(logFormatField string) string {
+	if v, ok := fieldByLogFormatField[logFormatField]; ok {
+		return v
+	}
+	// unknown field, convert to camel case
+	parts := strings.Split(logFormatField, "-")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// ParseRecord parses space separated flow log record in the log format fields order. Returned record is keyed by
+// Logs Insights field names, so it can be used the same way as query results.
+func (f FlowLogFields) ParseRecord(line string) (map[string]string, error) {
+	values := strings.Fields(line)
+	if len(values) != len(f) {
+		return nil, fmt.Errorf("record has %d values, expected %d", len(values), len(f))
+	}
+
+	out := make(map[string]string, len(values))
+	for i, v := range values {
+		out[ToField(f[i])] = v
+	}
+	return out, nil
+}
+
+// ToLogGroupName returns log group name from @log field (account-id:log-group-name) or log group ARN
+func ToLogGroupName(in string) string {
+	if strings.HasPrefix(in, "arn:") {
+		_, name, _ := strings.Cut(in, ":log-group:")
+		return strings.TrimSuffix(name, ":*")
+	}
+	if _, name, ok := strings.Cut(in, ":"); ok {
+		return name
+	}
+	return in
+}
+
+// IsLogFormatHeader returns true if all values of the line are log format fields (e.g. 'version account-id ...' or
+// '${version} ${account-id} ...'), flow log files delivered to S3 start with such header line
+func IsLogFormatHeader(line string) bool {
+	if strings.TrimSpace(line) == "" {
+		return false
+	}
+	for _, v := range ParseLogFormat(line) {
+		if _, ok := fieldByLogFormatField[v]; !ok {
+			return false
+		}
+	}
+	return true
+}
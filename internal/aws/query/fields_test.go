@@ -0,0 +1,136 @@
+package query
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToLogGroupName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"123456789012:/fl-cli/vpc-abc", "/fl-cli/vpc-abc"},
+		{"arn:aws:logs:eu-west-2:123456789012:log-group:/fl-cli/vpc-abc", "/fl-cli/vpc-abc"},
+		{"arn:aws:logs:eu-west-2:123456789012:log-group:/fl-cli/vpc-abc:*", "/fl-cli/vpc-abc"},
+		{"/fl-cli/vpc-abc", "/fl-cli/vpc-abc"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			if got := ToLogGroupName(tc.in); got != tc.want {
+				t.Errorf("ToLogGroupName(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestToField(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"interface-id", "interfaceId"},
+		{"srcaddr", "srcAddr"},
+		{"pkt-dstaddr", "pktDstAddr"},
+		{"log-status", "logStatus"},
+		{"pkt-src-aws-service", "pktSrcAwsService"},
+		{"ecs-service-name", "ecsServiceName"},
+		{"some-future-field", "someFutureField"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			if got := ToField(tc.in); got != tc.want {
+				t.Errorf("ToField(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseRecord(t *testing.T) {
+	values := []string{
+		"eni-123", "10.0.0.1", "52.1.2.3", "45678", "443", "6", "10", "840", "1733323807", "1733323867",
+		"ACCEPT", "OK",
+		"vpc-1", "subnet-1", "i-1", "18", "IPv4", "10.0.0.1", "52.1.2.3",
+		"-", "S3", "egress", "8",
+	}
+
+	got, err := FlowLogFieldsV2V5.ParseRecord(strings.Join(values, " "))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"interfaceId":      "eni-123",
+		"srcAddr":          "10.0.0.1",
+		"dstPort":          "443",
+		"logStatus":        "OK",
+		"tcpFlags":         "18",
+		"pktSrcAwsService": "-",
+		"pktDstAwsService": "S3",
+		"flowDirection":    "egress",
+		"trafficPath":      "8",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("field %s: got %q, want %q", k, got[k], v)
+		}
+	}
+	if len(got) != len(FlowLogFieldsV2V5) {
+		t.Errorf("expected %d fields, got %d", len(FlowLogFieldsV2V5), len(got))
+	}
+}
+
+func TestParseRecordInvalidLength(t *testing.T) {
+	if _, err := FlowLogFieldsV2V5.ParseRecord("eni-123 10.0.0.1"); err == nil {
+		t.Error("expected error for record with missing values")
+	}
+}
+
+func TestParseLogFormat(t *testing.T) {
+	fields := ParseLogFormat("${version} ${interface-id} ${srcaddr} ${flow-direction}")
+	want := FlowLogFields{"version", "interface-id", "srcaddr", "flow-direction"}
+	if strings.Join(fields, " ") != strings.Join(want, " ") {
+		t.Errorf("ParseLogFormat() = %v, want %v", fields, want)
+	}
+	if !fields.Contains("flowDirection") {
+		t.Error("fields should contain flowDirection")
+	}
+	if fields.Contains("dstAddr") {
+		t.Error("fields should not contain dstAddr")
+	}
+}
+
+func TestParseLogFormatDefault(t *testing.T) {
+	fields := ParseLogFormat("")
+	if len(fields) != 14 {
+		t.Errorf("default format: got %d fields, want 14", len(fields))
+	}
+	if fields.Contains("flowDirection") {
+		t.Error("default format should not contain flowDirection")
+	}
+	if !fields.Contains("logStatus") {
+		t.Error("default format should contain logStatus")
+	}
+}
+
+func TestIsLogFormatHeader(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"version account-id interface-id srcaddr dstaddr", true},
+		{"${version} ${srcaddr} ${flow-direction}", true},
+		{"2 123456789012 eni-123 10.0.0.1 52.1.2.3", false},
+		{"version some-future-field", false},
+		{"", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			if got := IsLogFormatHeader(tc.in); got != tc.want {
+				t.Errorf("IsLogFormatHeader(%q) = %t, want %t", tc.in, got, tc.want)
+			}
+		})
+	}
+}
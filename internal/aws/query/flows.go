@@ -0,0 +1,90 @@
+package query
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// flowMergeGap is maximum gap (seconds) between end of a record and start of the next record of the same flow, it is
+// the maximum flow log aggregation interval (10 minutes)
+const flowMergeGap = 10 * 60
+
+// flowKeyFields identify flow - network interface and 5-tuple
+var flowKeyFields = []string{"interfaceId", "protocol", "srcAddr", "srcPort", "dstAddr", "dstPort"}
+
+// MergeFlows merges flow log records (keyed by query field names) of the same flow (network interface, protocol,
+// source and destination address and port) in consecutive aggregation windows to a single record. Start is first
+// seen, end is last seen, bytes and packets are summed, tcp flags are OR-ed and RecordsField is number of merged
+// records. Records without start time (e.g. NODATA) are not merged. Merged flows are sorted by bytes, largest first.
+func MergeFlows(rows []map[string]string) []map[string]string {
+	var keys []string
+	rowsByKey := make(map[string][]map[string]string)
+	for _, row := range rows {
+		var values []string
+		for _, field := range flowKeyFields {
+			values = append(values, row[field])
+		}
+		key := strings.Join(values, "\x00")
+		if _, ok := rowsByKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		rowsByKey[key] = append(rowsByKey[key], row)
+	}
+
+	var out []map[string]string
+	for _, key := range keys {
+		flowRows := rowsByKey[key]
+		slices.SortStableFunc(flowRows, func(x, y map[string]string) int {
+			return cmp.Compare(ToInt64(x["start"]), ToInt64(y["start"]))
+		})
+
+		var merged map[string]string
+		for _, row := range flowRows {
+			start, err := strconv.ParseInt(row["start"], 10, 64)
+			if err != nil {
+				out = append(out, newMergedFlow(row))
+				continue
+			}
+			if merged != nil && start <= ToInt64(merged["end"])+flowMergeGap {
+				mergeFlow(merged, row)
+				continue
+			}
+			merged = newMergedFlow(row)
+			out = append(out, merged)
+		}
+	}
+
+	slices.SortStableFunc(out, func(x, y map[string]string) int {
+		return cmp.Compare(ToInt64(y["bytes"]), ToInt64(x["bytes"]))
+	})
+	return out
+}
+
+func newMergedFlow(row map[string]string) map[string]string {
+	out := make(map[string]string, len(row)+1)
+	for k, v := range row {
+		out[k] = v
+	}
+	out[RecordsField] = "1"
+	return out
+}
+
+// mergeFlow merges the next record of the flow to the merged record
+func mergeFlow(merged, row map[string]string) {
+	merged[RecordsField] = strconv.FormatInt(ToInt64(merged[RecordsField])+1, 10)
+	merged["end"] = strconv.FormatInt(max(ToInt64(merged["end"]), ToInt64(row["end"])), 10)
+	merged["bytes"] = strconv.FormatInt(ToInt64(merged["bytes"])+ToInt64(row["bytes"]), 10)
+	merged["packets"] = strconv.FormatInt(ToInt64(merged["packets"])+ToInt64(row["packets"]), 10)
+	// latest record time, merged flows are displayed at the time they were last seen
+	merged["@timestamp"] = max(merged["@timestamp"], row["@timestamp"])
+
+	if flags, err := strconv.Atoi(row["tcpFlags"]); err == nil {
+		mergedFlags, _ := strconv.Atoi(merged["tcpFlags"])
+		merged["tcpFlags"] = strconv.Itoa(mergedFlags | flags)
+	}
+	if merged["action"] != row["action"] && !slices.Contains(strings.Split(merged["action"], ", "), row["action"]) {
+		merged["action"] = merged["action"] + ", " + row["action"]
+	}
+}
@@ -0,0 +1,51 @@
+package query
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMergeFlows(t *testing.T) {
+	record := func(srcPort, start, end, bytes, tcpFlags, action string) map[string]string {
+		return map[string]string{
+			"interfaceId": "eni-1", "protocol": "6", "srcAddr": "10.0.1.5", "dstAddr": "10.0.2.9", "srcPort": srcPort,
+			"dstPort": "5432", "start": start, "end": end, "bytes": bytes, "packets": "1", "tcpFlags": tcpFlags,
+			"action": action, "@timestamp": "2024-12-04 14:50:07.000",
+		}
+	}
+	rows := []map[string]string{
+		// database session split to three windows, second window is out of order
+		record("40000", "1000", "1060", "100", "2", "ACCEPT"),
+		record("40000", "1120", "1180", "300", "1", "ACCEPT"),
+		record("40000", "1060", "1120", "200", "0", "ACCEPT"),
+		// the same tuple seen again after long gap is new flow
+		record("40000", "5000", "5060", "50", "2", "REJECT"),
+		// different source port is different flow
+		record("40001", "1000", "1060", "1000", "19", "ACCEPT"),
+		{"interfaceId": "eni-1", "srcAddr": "-", "start": "-", "logStatus": "NODATA"},
+	}
+
+	rows[1]["@timestamp"] = "2024-12-04 14:53:00.000"
+
+	got := MergeFlows(rows)
+	var summary []string
+	for _, row := range got {
+		summary = append(summary, fmt.Sprintf("%s %s-%s bytes=%s packets=%s flags=%s records=%s %s",
+			row["srcPort"], row["start"], row["end"], row["bytes"], row["packets"], row["tcpFlags"], row[RecordsField], row["action"]))
+	}
+	want := []string{
+		"40001 1000-1060 bytes=1000 packets=1 flags=19 records=1 ACCEPT",
+		"40000 1000-1180 bytes=600 packets=3 flags=3 records=3 ACCEPT",
+		"40000 5000-5060 bytes=50 packets=1 flags=2 records=1 REJECT",
+		" -- bytes= packets= flags= records=1 ",
+	}
+	if fmt.Sprint(summary) != fmt.Sprint(want) {
+		t.Errorf("MergeFlows()\n  got:  %q\n  want: %q", summary, want)
+	}
+	if got[1]["@timestamp"] != "2024-12-04 14:53:00.000" {
+		t.Errorf("merged @timestamp = %q, want the latest", got[1]["@timestamp"])
+	}
+	if rows[0]["bytes"] != "100" {
+		t.Error("MergeFlows() modified input records")
+	}
+}
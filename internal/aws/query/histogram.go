@@ -0,0 +1,114 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// binUnits are Logs Insights bin function units, from the largest
+var binUnits = []string{"w", "d", "h", "m", "s"}
+
+// ParseBin parses histogram time bucket size, e.g. 30s, 5m, 1h, 1d
+func ParseBin(in string) (time.Duration, error) {
+	in = strings.TrimSpace(in)
+	d, ok := parseDuration(in)
+	if !ok || d <= 0 || strings.HasPrefix(in, "-") {
+		return 0, fmt.Errorf("invalid histogram bucket %q, expected number and unit - s, m, h, d, w (e.g. 5m)", in)
+	}
+	return d, nil
+}
+
+// NewHistogram creates aggregation of flow logs to time buckets, optionally split by group by keys (e.g. action,
+// direction). Metric (bytes, packets or count) is charted and results are sorted by time.
+func NewHistogram(bin time.Duration, groupBy []string, metric string) (Aggregation, error) {
+	if bin < time.Second || bin%time.Second != 0 {
+		return Aggregation{}, fmt.Errorf("invalid histogram bucket %s, bucket has to be whole number of seconds", bin)
+	}
+	aggregation, err := NewAggregation(groupBy, metric)
+	if err != nil {
+		return Aggregation{}, err
+	}
+	aggregation.Bin = bin
+	return aggregation, nil
+}
+
+// IsHistogram returns true if the aggregation groups flow logs to time buckets
+func (a Aggregation) IsHistogram() bool {
+	return a.Bin > 0
+}
+
+// BinField returns name of the time bucket field in results, e.g. bin(5m)
+func (a Aggregation) BinField() string {
+	for _, unit := range binUnits {
+		if d := durationUnits[unit]; a.Bin%d == 0 {
+			return fmt.Sprintf("bin(%d%s)", a.Bin/d, unit)
+		}
+	}
+	return fmt.Sprintf("bin(%ds)", int64(a.Bin.Seconds()))
+}
+
+// bin returns start of the time bucket of the @timestamp field, in the same format as Logs Insights bin function
+func (a Aggregation) bin(timestamp string) string {
+	t, err := time.Parse("2006-01-02 15:04:05.999", timestamp)
+	if err != nil {
+		return ""
+	}
+	seconds := int64(a.Bin.Seconds())
+	return time.Unix(t.Unix()-t.Unix()%seconds, 0).UTC().Format(InsightsTimeLayout)
+}
+
+// FillBins adds empty time buckets (zero records, packets and bytes) between the first and the last bucket of
+// histogram results, for every group by values, so gaps in the traffic are visible. Rows without valid time bucket are
+// dropped. Results are sorted by time.
+func (a Aggregation) FillBins(rows []map[string]string) []map[string]string {
+	if !a.IsHistogram() || len(rows) == 0 {
+		return rows
+	}
+
+	binField := a.BinField()
+	var valid []map[string]string
+	var first, last time.Time
+	var seriesKeys []string
+	seriesByKey := make(map[string]map[string]string)
+	existing := make(map[string]struct{})
+	for _, row := range rows {
+		t, err := time.Parse(InsightsTimeLayout, row[binField])
+		if err != nil {
+			continue
+		}
+		valid = append(valid, row)
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+		if t.After(last) {
+			last = t
+		}
+		key := a.groupKey(row)
+		if _, ok := seriesByKey[key]; !ok {
+			seriesKeys = append(seriesKeys, key)
+			seriesByKey[key] = row
+		}
+		existing[row[binField]+"\x00"+key] = struct{}{}
+	}
+	// do not generate more buckets than Logs Insights can return
+	if first.IsZero() || last.Sub(first)/a.Bin > MaxLimit {
+		return valid
+	}
+
+	out := valid
+	for t := first; !t.After(last); t = t.Add(a.Bin) {
+		bin := t.Format(InsightsTimeLayout)
+		for _, key := range seriesKeys {
+			if _, ok := existing[bin+"\x00"+key]; ok {
+				continue
+			}
+			row := map[string]string{binField: bin, RecordsField: "0", PacketsField: "0", BytesField: "0"}
+			for _, field := range a.GroupBy {
+				row[field] = seriesByKey[key][field]
+			}
+			out = append(out, row)
+		}
+	}
+	return a.Merge(out)
+}
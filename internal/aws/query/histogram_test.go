@@ -0,0 +1,158 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBin(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"30s", 30 * time.Second},
+		{"5m", 5 * time.Minute},
+		{"1h", time.Hour},
+		{"1d", 24 * time.Hour},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseBin(tc.in)
+			if err != nil {
+				t.Fatalf("ParseBin() unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("ParseBin() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+
+	for _, in := range []string{"", "0m", "-5m", "5", "5y", "m"} {
+		if _, err := ParseBin(in); err == nil {
+			t.Errorf("ParseBin(%q) expected error", in)
+		}
+	}
+}
+
+func TestQueryHistogram(t *testing.T) {
+	tests := []struct {
+		bin     time.Duration
+		groupBy []string
+		want    string
+	}{
+		{5 * time.Minute, nil, "| stats count(*) as records, sum(packets) as totalPackets, sum(bytes) as totalBytes by bin(5m)"},
+		{90 * time.Minute, []string{"action"}, "| stats count(*) as records, sum(packets) as totalPackets, sum(bytes) as totalBytes by bin(90m), action"},
+		{7 * 24 * time.Hour, []string{"direction"}, "| stats count(*) as records, sum(packets) as totalPackets, sum(bytes) as totalBytes by bin(1w), flowDirection"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.want, func(t *testing.T) {
+			aggregation, err := NewHistogram(tc.bin, tc.groupBy, "count")
+			if err != nil {
+				t.Fatalf("NewHistogram() unexpected error: %v", err)
+			}
+			q := NewQuery(100, 60).Stats(aggregation)
+			lines := strings.Split(q.GetQuery(), "\n")
+			if got := lines[len(lines)-1]; got != tc.want {
+				t.Errorf("query tail\n  got:  %q\n  want: %q", got, tc.want)
+			}
+			if q.GetLimit() != MaxLimit {
+				t.Errorf("GetLimit() = %d, want %d", q.GetLimit(), MaxLimit)
+			}
+		})
+	}
+
+	if _, err := NewHistogram(1500*time.Millisecond, nil, "count"); err == nil {
+		t.Error("NewHistogram() expected error for fractional seconds")
+	}
+}
+
+func TestHistogramMergeAndFill(t *testing.T) {
+	aggregation, err := NewHistogram(time.Minute, []string{"action"}, "bytes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch1 := []map[string]string{
+		{"bin(1m)": "2024-12-04 14:53:00.000", "action": "REJECT", RecordsField: "1", PacketsField: "1", BytesField: "40"},
+		{"bin(1m)": "2024-12-04 14:50:00.000", "action": "ACCEPT", RecordsField: "2", PacketsField: "4", BytesField: "400"},
+	}
+	batch2 := []map[string]string{
+		{"bin(1m)": "2024-12-04 14:50:00.000", "action": "ACCEPT", RecordsField: "1", PacketsField: "2", BytesField: "100"},
+	}
+
+	merged := aggregation.Merge(batch1, batch2)
+	want := "[map[action:ACCEPT bin(1m):2024-12-04 14:50:00.000 records:3 totalBytes:500 totalPackets:6] " +
+		"map[action:REJECT bin(1m):2024-12-04 14:53:00.000 records:1 totalBytes:40 totalPackets:1]]"
+	if got := fmt.Sprint(merged); got != want {
+		t.Errorf("Merge()\n  got:  %s\n  want: %s", got, want)
+	}
+
+	filled := aggregation.FillBins(merged)
+	var got []string
+	for _, row := range filled {
+		got = append(got, fmt.Sprintf("%s %s %s", row["bin(1m)"][11:16], row["action"], row[RecordsField]))
+	}
+	wantFilled := []string{
+		"14:50 ACCEPT 3", "14:50 REJECT 0",
+		"14:51 ACCEPT 0", "14:51 REJECT 0",
+		"14:52 ACCEPT 0", "14:52 REJECT 0",
+		"14:53 ACCEPT 0", "14:53 REJECT 1",
+	}
+	if strings.Join(got, ", ") != strings.Join(wantFilled, ", ") {
+		t.Errorf("FillBins()\n  got:  %v\n  want: %v", got, wantFilled)
+	}
+}
+
+func TestEvaluateHistogram(t *testing.T) {
+	aggregation, err := NewHistogram(2*time.Second, nil, "count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, _, err := NewQuery(100, 60).NoNoData().Stats(aggregation).Evaluate(records)
+	if err != nil {
+		t.Fatalf("Evaluate() unexpected error: %v", err)
+	}
+	var got []string
+	for _, row := range results {
+		got = append(got, fmt.Sprintf("%s %s", row["bin(2s)"][17:19], row[RecordsField]))
+	}
+	want := []string{"00 1", "02 2", "04 2", "06 1"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Evaluate() = %v, want %v", got, want)
+	}
+}
+
+func TestHistogramWithoutTimestamp(t *testing.T) {
+	aggregation, err := NewHistogram(5*time.Minute, nil, "count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// exported records without start field have no @timestamp
+	in := []map[string]string{
+		{"@ptr": "1", "@timestamp": "", "bytes": "100"},
+		{"@ptr": "2", "@timestamp": "2024-12-04 14:52:07.000", "bytes": "40"},
+		{"@ptr": "3", "bytes": "10"},
+	}
+	results, _, err := NewQuery(100, 60).Stats(aggregation).Evaluate(in)
+	if err != nil {
+		t.Fatalf("Evaluate() unexpected error: %v", err)
+	}
+	want := "[map[bin(5m):2024-12-04 14:50:00.000 records:1 totalBytes:40 totalPackets:0]]"
+	if got := fmt.Sprint(results); got != want {
+		t.Errorf("Evaluate()\n  got:  %s\n  want: %s", got, want)
+	}
+
+	rows := []map[string]string{
+		{"bin(5m)": "", RecordsField: "1", PacketsField: "1", BytesField: "100"},
+		{"bin(5m)": "2024-12-04 14:50:00.000", RecordsField: "1", PacketsField: "1", BytesField: "40"},
+	}
+	if got := aggregation.FillBins(rows); len(got) != 1 || got[0]["bin(5m)"] != "2024-12-04 14:50:00.000" {
+		t.Errorf("FillBins() = %v, want only valid time bucket", got)
+	}
+	if got := aggregation.FillBins(rows[:1]); len(got) != 0 {
+		t.Errorf("FillBins() = %v, want no rows", got)
+	}
+}
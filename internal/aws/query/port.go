@@ -0,0 +1,112 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const maxPort = 65535
+
+// PortRange is inclusive range of ports, single port has the same from and to values
+type PortRange struct {
+	From int
+	To   int
+}
+
+func (p PortRange) contains(port int) bool {
+	return port >= p.From && port <= p.To
+}
+
+type Ports []PortRange
+
+// ParsePorts parses comma separated list of ports and port ranges e.g. '22,3389,1024-65535'
+func ParsePorts(in string) (Ports, error) {
+	var out Ports
+	for _, v := range strings.Split(in, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(v, "-")
+		fromPort, err := parsePort(from)
+		if err != nil {
+			return nil, err
+		}
+		toPort := fromPort
+		if isRange {
+			if toPort, err = parsePort(to); err != nil {
+				return nil, err
+			}
+		}
+		if fromPort > toPort {
+			return nil, fmt.Errorf("invalid port range %q, start is greater than end", v)
+		}
+		out = append(out, PortRange{From: fromPort, To: toPort})
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("invalid ports %q", in)
+	}
+	return out, nil
+}
+
+func parsePort(in string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(in))
+	if err != nil || port < 0 || port > maxPort {
+		return 0, fmt.Errorf("invalid port %q, port has to be number between 0 and %d", in, maxPort)
+	}
+	return port, nil
+}
+
+// validate returns error if any of the ports is out of range, or range start is greater than end
+func (p Ports) validate() error {
+	if len(p) == 0 {
+		return errors.New("no ports")
+	}
+	for _, r := range p {
+		if r.From < 0 || r.To > maxPort {
+			return fmt.Errorf("invalid port %d-%d, port has to be number between 0 and %d", r.From, r.To, maxPort)
+		}
+		if r.From > r.To {
+			return fmt.Errorf("invalid port range %d-%d, start is greater than end", r.From, r.To)
+		}
+	}
+	return nil
+}
+
+// expression returns filter expression for the field. Single ports are grouped to 'in' expression, ranges are
+// expressed as '>=' and '<=' conditions
+func (p Ports) expression(field string) Expr {
+	var singles []Value
+	var ranges []Expr
+	for _, r := range p {
+		if r.From == r.To {
+			singles = append(singles, NumberValue(r.From))
+			continue
+		}
+		ranges = append(ranges, And(Compare(field, Ge, NumberValue(r.From)), Compare(field, Le, NumberValue(r.To))))
+	}
+
+	var exprs []Expr
+	switch len(singles) {
+	case 0:
+	case 1:
+		exprs = append(exprs, Compare(field, Eq, singles[0]))
+	default:
+		exprs = append(exprs, In(field, singles...))
+	}
+	return Or(append(exprs, ranges...)...)
+}
+
+// portsFilter returns filter that matches if any of the fields matches the ports
+func portsFilter(ports Ports, fields ...string) (Expr, error) {
+	if err := ports.validate(); err != nil {
+		return nil, err
+	}
+	var exprs []Expr
+	for _, field := range fields {
+		exprs = append(exprs, ports.expression(field))
+	}
+	return Or(exprs...), nil
+}
@@ -0,0 +1,89 @@
+package query
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Ports
+	}{
+		{"22", Ports{{22, 22}}},
+		{"22,3389,5985", Ports{{22, 22}, {3389, 3389}, {5985, 5985}}},
+		{"1024-65535", Ports{{1024, 65535}}},
+		{"22, 80-90 ,443", Ports{{22, 22}, {80, 90}, {443, 443}}},
+		{"0", Ports{{0, 0}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParsePorts(tc.in)
+			if err != nil {
+				t.Fatalf("ParsePorts(%q) unexpected error: %v", tc.in, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ParsePorts(%q) = %v, want %v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParsePortsInvalid(t *testing.T) {
+	for _, in := range []string{"", ",", "abc", "65536", "-1", "90-80", "22-", "1-2-3", `22"`} {
+		t.Run(in, func(t *testing.T) {
+			if _, err := ParsePorts(in); err == nil {
+				t.Errorf("ParsePorts(%q) expected error", in)
+			}
+		})
+	}
+}
+
+func TestQueryPorts(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(Query) Query
+		want string
+	}{
+		{"single port", func(q Query) Query { return q.DestinationPorts(Ports{{22, 22}}) }, `| filter dstPort == 22`},
+		{"port list", func(q Query) Query { return q.DestinationPorts(Ports{{22, 22}, {3389, 3389}}) }, `| filter dstPort in [22, 3389]`},
+		{"port range", func(q Query) Query { return q.SourcePorts(Ports{{1024, 65535}}) }, `| filter (srcPort >= 1024 and srcPort <= 65535)`},
+		{"mixed", func(q Query) Query { return q.SourcePorts(Ports{{22, 22}, {80, 90}, {443, 443}}) }, `| filter srcPort in [22, 443] or (srcPort >= 80 and srcPort <= 90)`},
+		{"source or destination", func(q Query) Query { return q.Ports(Ports{{22, 22}, {3389, 3389}}) }, `| filter srcPort in [22, 3389] or dstPort in [22, 3389]`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.fn(NewQuery(100, 60)).GetQuery()
+			if !strings.Contains(got, tc.want) {
+				t.Errorf("query missing clause\n  got:  %q\n  want: %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestQueryPortsMatch(t *testing.T) {
+	row := map[string]string{"srcPort": "45678", "dstPort": "3389"}
+
+	tests := []struct {
+		name string
+		q    Query
+		want bool
+	}{
+		{"destination in list", NewQuery(100, 60).DestinationPorts(Ports{{22, 22}, {3389, 3389}}), true},
+		{"destination not in list", NewQuery(100, 60).DestinationPorts(Ports{{22, 22}, {5985, 5985}}), false},
+		{"source in range", NewQuery(100, 60).SourcePorts(Ports{{1024, 65535}}), true},
+		{"source not in range", NewQuery(100, 60).SourcePorts(Ports{{1, 1023}}), false},
+		{"any port", NewQuery(100, 60).Ports(Ports{{3389, 3389}}), true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.q.Match(row); got != tc.want {
+				t.Errorf("Match() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package query
 
 import (
+	"errors"
+	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -23,6 +26,44 @@ func protocolFromKeywordToNumber(in string) int {
 	return -1
 }
 
+// ParseProtocol returns protocol number from protocol keyword (e.g. tcp, udp) or number
+func ParseProtocol(in string) (int, error) {
+	in = strings.TrimSpace(in)
+	if n, err := strconv.Atoi(in); err == nil {
+		if n < 0 || n > 255 {
+			return -1, fmt.Errorf("invalid protocol number %d, protocol has to be number between 0 and 255", n)
+		}
+		return n, nil
+	}
+	if n := protocolFromKeywordToNumber(in); n >= 0 {
+		return n, nil
+	}
+	return -1, fmt.Errorf("unknown protocol %q", in)
+}
+
+// protocolsFilter returns filter expression for protocols, error is returned if any of the protocols is unknown
+func protocolsFilter(protos []string) (Expr, error) {
+	var numbers []Value
+	for _, proto := range protos {
+		protoNumber, err := ParseProtocol(proto)
+		if err != nil {
+			return nil, err
+		}
+		if n := NumberValue(protoNumber); !slices.Contains(numbers, n) {
+			numbers = append(numbers, n)
+		}
+	}
+
+	switch len(numbers) {
+	case 0:
+		return nil, errors.New("no protocols")
+	case 1:
+		return Compare("protocol", Eq, numbers[0]), nil
+	default:
+		return In("protocol", numbers...), nil
+	}
+}
+
 func ProtocolFromNumberToKeyword(in string) string {
 	if v := toProtocol(in).keyword; v != "" {
 		return v
@@ -2,6 +2,7 @@ package query
 
 import (
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -66,3 +67,72 @@ func TestProtocolRoundTrip(t *testing.T) {
 		}
 	}
 }
+
+func TestParseProtocol(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"tcp", 6},
+		{" UDP ", 17},
+		{"6", 6},
+		{"0", 0},
+		{"255", 255},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseProtocol(tc.in)
+			if err != nil {
+				t.Fatalf("ParseProtocol(%q) unexpected error: %v", tc.in, err)
+			}
+			if got != tc.want {
+				t.Errorf("ParseProtocol(%q) = %d, want %d", tc.in, got, tc.want)
+			}
+		})
+	}
+
+	for _, in := range []string{"", "not-a-protocol", "256", "-1"} {
+		if _, err := ParseProtocol(in); err == nil {
+			t.Errorf("ParseProtocol(%q) expected error", in)
+		}
+	}
+}
+
+func TestQueryProtocols(t *testing.T) {
+	tests := []struct {
+		name   string
+		protos []string
+		want   string
+	}{
+		{"single", []string{"tcp"}, `| filter protocol == 6`},
+		{"multiple", []string{"tcp", "udp"}, `| filter protocol in [6, 17]`},
+		{"number and keyword", []string{"1", "tcp"}, `| filter protocol in [1, 6]`},
+		{"duplicates", []string{"tcp", "6"}, `| filter protocol == 6`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := NewQuery(100, 60).Protocols(tc.protos...).GetQuery()
+			if !strings.Contains(got, tc.want) {
+				t.Errorf("query missing clause\n  got:  %q\n  want: %q", got, tc.want)
+			}
+		})
+	}
+
+	q := NewQuery(100, 60).Protocols("tcp", "udp")
+	if !q.Match(map[string]string{"protocol": "17"}) || q.Match(map[string]string{"protocol": "1"}) {
+		t.Errorf("unexpected match result for %q", q.GetQuery())
+	}
+}
+
+func TestQueryProtocolsUnknown(t *testing.T) {
+	base := NewQuery(100, 60)
+	q := base.Protocols("tcp", "not-a-protocol")
+	if q.Err() == nil {
+		t.Error("unknown protocol should return error")
+	}
+	if q.GetQuery() != base.GetQuery() {
+		t.Errorf("unknown protocol should leave query unchanged, got %q", q.GetQuery())
+	}
+}
@@ -1,117 +1,383 @@
 package query
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 )
 
-// Query is request to query cloud watch flow logs
+// MaxLimit is maximum number of results returned by Logs Insights query
+const MaxLimit = 10000
+
+// Query is request to query cloud watch flow logs. Query is pipeline of stages, filters are typed expressions (see
+// Expr) that are rendered to Logs Insights syntax. Invalid input (e.g. address or protocol) does not change the query,
+// the error is recorded and returned by Err.
 type Query struct {
-	query        []string
-	limit        int
-	sinceMinutes int
+	stages      []stage
+	limit       int
+	complete    bool
+	aggregation *Aggregation
+	raw         bool
+	unmergeable bool
+	errs        []error
+	start       time.Time
+	end         time.Time
+}
+
+// stage is single stage of the query pipeline
+type stage interface {
+	insights() string
+}
+
+// fieldsStage selects fields returned by the query
+type fieldsStage []string
+
+func (s fieldsStage) insights() string {
+	return fmt.Sprintf("fields %s", strings.Join(s, ", "))
 }
 
+// filterStage filters records by expression
+type filterStage struct {
+	expr Expr
+}
+
+func (s filterStage) insights() string {
+	return fmt.Sprintf("| filter %s", s.expr.Insights())
+}
+
+// rawStage is generated (sort, stats) or user supplied (Insights) stage in Logs Insights syntax
+type rawStage string
+
+func (s rawStage) insights() string {
+	return string(s)
+}
+
+// NewQuery creates query for the last 'sinceMinutes' minutes, use TimeRange to query specific time window
 func NewQuery(limit, sinceMinutes int) Query {
+	end := time.Now()
 	return Query{
-		query:        []string{fmt.Sprintf("fields %s", strings.Join(Fields, ", "))},
-		limit:        limit,
-		sinceMinutes: sinceMinutes,
+		stages: []stage{fieldsStage(Fields)},
+		limit:  limit,
+		start:  end.Add(time.Duration(-sinceMinutes) * time.Minute),
+		end:    end,
 	}
 }
 
+// TimeRange sets start and end time of the query
+func (q Query) TimeRange(start, end time.Time) Query {
+	q.start = start
+	q.end = end
+	return q
+}
+
+// Complete sets query to return all results in the time range, ignoring limit
+func (q Query) Complete() Query {
+	q.complete = true
+	return q
+}
+
 func (q Query) NoNoData() Query {
-	return q.add(`| filter logStatus != "NODATA"`)
+	return q.Where(Compare("logStatus", Ne, StringValue("NODATA")))
 }
 
 func (q Query) NoSkipData() Query {
-	return q.add(`| filter logStatus != "SKIPDATA"`)
+	return q.Where(Compare("logStatus", Ne, StringValue("SKIPDATA")))
 }
 
 func (q Query) InterfaceId(id string) Query {
-	return q.add(fmt.Sprintf(`| filter interfaceId == "%s"`, id))
+	return q.Where(Compare("interfaceId", Eq, StringValue(id)))
+}
+
+func (q Query) NotInterfaceId(id string) Query {
+	return q.Where(Not(Compare("interfaceId", Eq, StringValue(id))))
+}
+
+func (q Query) SubnetId(id string) Query {
+	return q.Where(Compare("subnetId", Eq, StringValue(id)))
+}
+
+func (q Query) InstanceId(id string) Query {
+	return q.Where(Compare("instanceId", Eq, StringValue(id)))
+}
+
+// TrafficType filters type field (IPv4, IPv6 or EFA), use ParseTrafficType to validate user input
+func (q Query) TrafficType(trafficType string) Query {
+	return q.Where(Compare("type", Eq, StringValue(trafficType)))
+}
+
+// AwsService filters packet source or destination AWS service (e.g. S3, DYNAMODB), service name is case-insensitive
+func (q Query) AwsService(service string) Query {
+	service = strings.ToUpper(service)
+	return q.Where(Or(Compare("pktSrcAwsService", Eq, StringValue(service)), Compare("pktDstAwsService", Eq, StringValue(service))))
 }
 
 func (q Query) Ingress() Query {
-	return q.add(`| filter flowDirection == "ingress"`)
+	return q.Where(Compare("flowDirection", Eq, StringValue("ingress")))
 }
 
 func (q Query) Egress() Query {
-	return q.add(`| filter flowDirection == "egress"`)
+	return q.Where(Compare("flowDirection", Eq, StringValue("egress")))
 }
 
 func (q Query) Accept() Query {
-	return q.add(`| filter action == "ACCEPT"`)
+	return q.Where(Compare("action", Eq, StringValue("ACCEPT")))
 }
 
 func (q Query) Reject() Query {
-	return q.add(`| filter action == "REJECT"`)
+	return q.Where(Compare("action", Eq, StringValue("REJECT")))
 }
 
 func (q Query) Protocol(proto string) Query {
-	protoNumber := protocolFromKeywordToNumber(proto)
-	// not found, query all protocols
-	if protoNumber < 0 {
-		return q
+	return q.Protocols(proto)
+}
+
+// Protocols filters protocols by keyword (e.g. tcp, udp) or number, unknown protocol is an error
+func (q Query) Protocols(protos ...string) Query {
+	return q.whereErr(protocolsFilter(protos))
+}
+
+// NotProtocols excludes protocols by keyword (e.g. tcp, udp) or number, unknown protocol is an error
+func (q Query) NotProtocols(protos ...string) Query {
+	e, err := protocolsFilter(protos)
+	if err != nil {
+		return q.withErr(err)
 	}
-	return q.add(fmt.Sprintf(`| filter protocol == "%d"`, protoNumber))
+	return q.Where(Not(e))
+}
+
+// TcpFlags filters tcp flags, use ParseTcpFlags to create flags
+func (q Query) TcpFlags(flags TcpFlags) Query {
+	return q.Where(tcpFlagsFilter(flags))
 }
 
+// Port filters source or destination port
 func (q Query) Port(port int) Query {
-	return q.add(fmt.Sprintf(`| filter srcPort == "%d" or dstPort == "%d"`, port, port))
+	return q.Ports(Ports{{From: port, To: port}})
+}
+
+// Ports filters source or destination ports and port ranges
+func (q Query) Ports(ports Ports) Query {
+	return q.whereErr(portsFilter(ports, "srcPort", "dstPort"))
+}
+
+// NotPorts excludes source or destination ports and port ranges
+func (q Query) NotPorts(ports Ports) Query {
+	e, err := portsFilter(ports, "srcPort", "dstPort")
+	if err != nil {
+		return q.withErr(err)
+	}
+	return q.Where(Not(e))
 }
 
 func (q Query) SourcePort(port int) Query {
-	return q.add(fmt.Sprintf(`| filter srcPort == "%d"`, port))
+	return q.SourcePorts(Ports{{From: port, To: port}})
+}
+
+func (q Query) SourcePorts(ports Ports) Query {
+	return q.whereErr(portsFilter(ports, "srcPort"))
 }
 
 func (q Query) DestinationPort(port int) Query {
-	return q.add(fmt.Sprintf(`| filter dstPort == "%d"`, port))
+	return q.DestinationPorts(Ports{{From: port, To: port}})
+}
+
+func (q Query) DestinationPorts(ports Ports) Query {
+	return q.whereErr(portsFilter(ports, "dstPort"))
 }
 
+// Address filters source, destination or packet address, address can be IP or CIDR
 func (q Query) Address(addr string) Query {
-	return q.add(fmt.Sprintf(`| filter srcAddr == "%s" or pktSrcAddr == "%s" or dstAddr == "%s" or pktDstAddr == "%s"`, addr, addr, addr, addr))
+	return q.whereErr(addressFilter(addr, "srcAddr", "pktSrcAddr", "dstAddr", "pktDstAddr"))
+}
+
+// NotAddress excludes source, destination or packet address, address can be IP or CIDR
+func (q Query) NotAddress(addr string) Query {
+	e, err := addressFilter(addr, "srcAddr", "pktSrcAddr", "dstAddr", "pktDstAddr")
+	if err != nil {
+		return q.withErr(err)
+	}
+	return q.Where(Not(e))
 }
 
+// SourceAddress filters source address, address can be IP or CIDR
 func (q Query) SourceAddress(addr string) Query {
-	return q.add(fmt.Sprintf(`| filter srcAddr == "%s"`, addr))
+	return q.whereErr(addressFilter(addr, "srcAddr"))
 }
 
+// PktSourceAddress filters packet source address, address can be IP or CIDR
 func (q Query) PktSourceAddress(addr string) Query {
-	return q.add(fmt.Sprintf(`| filter pktSrcAddr == "%s"`, addr))
+	return q.whereErr(addressFilter(addr, "pktSrcAddr"))
 }
 
+// DestinationAddress filters destination address, address can be IP or CIDR
 func (q Query) DestinationAddress(addr string) Query {
-	return q.add(fmt.Sprintf(`| filter dstAddr == "%s"`, addr))
+	return q.whereErr(addressFilter(addr, "dstAddr"))
 }
 
+// PktDestinationAddress filters packet destination address, address can be IP or CIDR
 func (q Query) PktDestinationAddress(addr string) Query {
-	return q.add(fmt.Sprintf(`| filter pktDstAddr == "%s"`, addr))
+	return q.whereErr(addressFilter(addr, "pktDstAddr"))
+}
+
+// Insights adds raw Logs Insights pipeline (e.g. '| parse ... | dedup ...'). Pipeline starting with 'fields' replaces
+// generated fields clause, other stages are appended. Raw pipeline cannot be matched client-side.
+func (q Query) Insights(pipeline string) Query {
+	pipeline = strings.TrimSpace(pipeline)
+	if pipeline == "" {
+		return q
+	}
+	q.raw = true
+
+	if strings.HasPrefix(pipeline, "fields ") {
+		fields, rest, _ := strings.Cut(pipeline, "|")
+		next := make([]stage, len(q.stages))
+		copy(next, q.stages)
+		next[0] = rawStage(strings.TrimSpace(fields))
+		q.stages = next
+		if rest = strings.TrimSpace(rest); rest == "" {
+			return q
+		}
+		pipeline = fmt.Sprintf("| %s", rest)
+	}
+	if !strings.HasPrefix(pipeline, "|") {
+		pipeline = fmt.Sprintf("| %s", pipeline)
+	}
+	for _, command := range []string{"stats", "sort", "dedup"} {
+		q.unmergeable = q.unmergeable || hasCommand(pipeline, command)
+	}
+	return q.add(rawStage(pipeline))
+}
+
+// IsMergeable returns false if the raw pipeline aggregates, sorts or deduplicates records. Results of split time range
+// (complete query) and log group batches are concatenated and sorted by time, which is wrong for such pipelines.
+func (q Query) IsMergeable() bool {
+	return !q.unmergeable
+}
+
+// HasStage returns true if the query contains stage with the command (e.g. sort, stats), it is used to check raw
+// pipelines before adding generated stages
+func (q Query) HasStage(command string) bool {
+	for _, s := range q.stages {
+		if hasCommand(s.insights(), command) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCommand returns true if Logs Insights pipeline has stage with the command
+func hasCommand(pipeline, command string) bool {
+	for _, stage := range strings.Split(pipeline, "|") {
+		if fields := strings.Fields(stage); len(fields) > 0 && strings.EqualFold(fields[0], command) {
+			return true
+		}
+	}
+	return false
 }
 
 func (q Query) Sort() Query {
-	return q.add(`| sort @timestamp desc`)
+	return q.add(rawStage("| sort @timestamp desc"))
 }
 
-func (q Query) add(in string) Query {
-	next := make([]string, len(q.query)+1)
-	copy(next, q.query)
-	next[len(q.query)] = in
-	return Query{
-		query:        next,
-		limit:        q.limit,
-		sinceMinutes: q.sinceMinutes,
+// Stats aggregates flow logs and sorts results by aggregation metric, use instead of Sort. Histogram is not sorted
+// (results are sorted by Merge) and the query limit is raised, so all time buckets are returned.
+func (q Query) Stats(aggregation Aggregation) Query {
+	q.aggregation = &aggregation
+	if aggregation.IsHistogram() {
+		q.limit = MaxLimit
+		return q.add(rawStage(aggregation.stats()))
 	}
+	return q.add(rawStage(aggregation.stats())).add(rawStage(aggregation.sort()))
+}
+
+// Where adds filter stage with the expression, invalid expression is not added and the error is returned by Err
+func (q Query) Where(e Expr) Query {
+	if err := Validate(e); err != nil {
+		return q.withErr(err)
+	}
+	return q.add(filterStage{expr: e})
+}
+
+// whereErr adds filter stage with the expression, or records the error if expression could not be created
+func (q Query) whereErr(e Expr, err error) Query {
+	if err != nil {
+		return q.withErr(err)
+	}
+	return q.Where(e)
+}
+
+func (q Query) withErr(err error) Query {
+	next := make([]error, len(q.errs)+1)
+	copy(next, q.errs)
+	next[len(q.errs)] = err
+	q.errs = next
+	return q
+}
+
+// Err returns errors of invalid input (e.g. invalid address, port or unknown protocol), query should not be run if
+// there is an error
+func (q Query) Err() error {
+	return errors.Join(q.errs...)
+}
+
+// Match returns true if the flow log record (keyed by query field names) matches all query filters. It is used
+// when flow logs are not queried by Logs Insights, e.g. live tail.
+func (q Query) Match(row map[string]string) bool {
+	for _, s := range q.stages {
+		if f, ok := s.(filterStage); ok && !f.expr.Match(row) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q Query) add(in stage) Query {
+	next := make([]stage, len(q.stages)+1)
+	copy(next, q.stages)
+	next[len(q.stages)] = in
+	q.stages = next
+	return q
 }
 
 func (q Query) GetQuery() string {
-	return strings.Join(q.query, "\n")
+	var out []string
+	for _, s := range q.stages {
+		out = append(out, s.insights())
+	}
+	return strings.Join(out, "\n")
 }
 
 func (q Query) GetLimit() int {
 	return q.limit
 }
 
+// IsRaw returns true if the query contains raw Logs Insights pipeline, results might not have the standard fields
+func (q Query) IsRaw() bool {
+	return q.raw
+}
+
+func (q Query) IsComplete() bool {
+	return q.complete
+}
+
+// GetAggregation returns aggregation and true if the query aggregates flow logs
+func (q Query) GetAggregation() (Aggregation, bool) {
+	if q.aggregation == nil {
+		return Aggregation{}, false
+	}
+	return *q.aggregation, true
+}
+
+func (q Query) GetStart() time.Time {
+	return q.start
+}
+
+func (q Query) GetEnd() time.Time {
+	return q.end
+}
+
+// GetSinceMinutes returns length of the query time range in minutes
 func (q Query) GetSinceMinutes() int {
-	return q.sinceMinutes
+	return int(q.end.Sub(q.start).Minutes())
 }
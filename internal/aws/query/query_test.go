@@ -3,6 +3,7 @@ package query
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestNewQuery(t *testing.T) {
@@ -18,6 +19,21 @@ func TestNewQuery(t *testing.T) {
 	}
 }
 
+func TestQueryTimeRange(t *testing.T) {
+	start := time.Date(2024, 12, 3, 14, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 12, 3, 14, 20, 0, 0, time.UTC)
+	q := NewQuery(100, 60).TimeRange(start, end).Accept()
+	if !q.GetStart().Equal(start) {
+		t.Errorf("start: got %v, want %v", q.GetStart(), start)
+	}
+	if !q.GetEnd().Equal(end) {
+		t.Errorf("end: got %v, want %v", q.GetEnd(), end)
+	}
+	if q.GetSinceMinutes() != 20 {
+		t.Errorf("sinceMinutes: got %d, want 20", q.GetSinceMinutes())
+	}
+}
+
 func TestQueryFilters(t *testing.T) {
 	tests := []struct {
 		name string
@@ -27,15 +43,19 @@ func TestQueryFilters(t *testing.T) {
 		{"NoNoData", func(q Query) Query { return q.NoNoData() }, `| filter logStatus != "NODATA"`},
 		{"NoSkipData", func(q Query) Query { return q.NoSkipData() }, `| filter logStatus != "SKIPDATA"`},
 		{"InterfaceId", func(q Query) Query { return q.InterfaceId("eni-abc") }, `| filter interfaceId == "eni-abc"`},
+		{"SubnetId", func(q Query) Query { return q.SubnetId("subnet-abc") }, `| filter subnetId == "subnet-abc"`},
+		{"InstanceId", func(q Query) Query { return q.InstanceId("i-abc") }, `| filter instanceId == "i-abc"`},
+		{"TrafficType", func(q Query) Query { return q.TrafficType("IPv6") }, `| filter type == "IPv6"`},
+		{"AwsService", func(q Query) Query { return q.AwsService("dynamodb") }, `| filter pktSrcAwsService == "DYNAMODB" or pktDstAwsService == "DYNAMODB"`},
 		{"Ingress", func(q Query) Query { return q.Ingress() }, `| filter flowDirection == "ingress"`},
 		{"Egress", func(q Query) Query { return q.Egress() }, `| filter flowDirection == "egress"`},
 		{"Accept", func(q Query) Query { return q.Accept() }, `| filter action == "ACCEPT"`},
 		{"Reject", func(q Query) Query { return q.Reject() }, `| filter action == "REJECT"`},
-		{"Protocol TCP", func(q Query) Query { return q.Protocol("TCP") }, `| filter protocol == "6"`},
-		{"Protocol udp lowercase", func(q Query) Query { return q.Protocol("udp") }, `| filter protocol == "17"`},
-		{"Port", func(q Query) Query { return q.Port(443) }, `| filter srcPort == "443" or dstPort == "443"`},
-		{"SourcePort", func(q Query) Query { return q.SourcePort(22) }, `| filter srcPort == "22"`},
-		{"DestinationPort", func(q Query) Query { return q.DestinationPort(80) }, `| filter dstPort == "80"`},
+		{"Protocol TCP", func(q Query) Query { return q.Protocol("TCP") }, `| filter protocol == 6`},
+		{"Protocol udp lowercase", func(q Query) Query { return q.Protocol("udp") }, `| filter protocol == 17`},
+		{"Port", func(q Query) Query { return q.Port(443) }, `| filter srcPort == 443 or dstPort == 443`},
+		{"SourcePort", func(q Query) Query { return q.SourcePort(22) }, `| filter srcPort == 22`},
+		{"DestinationPort", func(q Query) Query { return q.DestinationPort(80) }, `| filter dstPort == 80`},
 		{"Address", func(q Query) Query { return q.Address("10.0.0.1") }, `| filter srcAddr == "10.0.0.1" or pktSrcAddr == "10.0.0.1" or dstAddr == "10.0.0.1" or pktDstAddr == "10.0.0.1"`},
 		{"SourceAddress", func(q Query) Query { return q.SourceAddress("10.0.0.2") }, `| filter srcAddr == "10.0.0.2"`},
 		{"PktSourceAddress", func(q Query) Query { return q.PktSourceAddress("10.0.0.3") }, `| filter pktSrcAddr == "10.0.0.3"`},
@@ -54,12 +74,57 @@ func TestQueryFilters(t *testing.T) {
 	}
 }
 
-func TestProtocolUnknownKeywordIsNoOp(t *testing.T) {
+func TestProtocolUnknownKeywordIsError(t *testing.T) {
 	base := NewQuery(100, 60)
-	got := base.Protocol("not-a-real-protocol").GetQuery()
-	if got != base.GetQuery() {
+	q := base.Protocol("not-a-real-protocol")
+	if got := q.GetQuery(); got != base.GetQuery() {
 		t.Errorf("unknown protocol should leave query unchanged\n  base: %q\n  got:  %q", base.GetQuery(), got)
 	}
+	if q.Err() == nil {
+		t.Error("unknown protocol should return error")
+	}
+	if base.Err() != nil {
+		t.Errorf("base query error: %v", base.Err())
+	}
+}
+
+func TestQueryInvalidInput(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(Query) Query
+	}{
+		{"address with quote", func(q Query) Query { return q.Address(`10.0.0.1" or srcAddr like /./`) }},
+		{"invalid CIDR", func(q Query) Query { return q.SourceAddress("10.0.0.0/33") }},
+		{"excluded address", func(q Query) Query { return q.NotAddress("not-an-ip") }},
+		{"port out of range", func(q Query) Query { return q.Port(70000) }},
+		{"port range", func(q Query) Query { return q.DestinationPorts(Ports{{90, 80}}) }},
+		{"no ports", func(q Query) Query { return q.NotPorts(nil) }},
+		{"invalid field", func(q Query) Query { return q.Where(Compare("dstPort | stats", Eq, NumberValue(1))) }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			base := NewQuery(100, 60)
+			q := tc.fn(base)
+			if q.Err() == nil {
+				t.Error("expected error")
+			}
+			if q.GetQuery() != base.GetQuery() {
+				t.Errorf("invalid input should leave query unchanged, got %q", q.GetQuery())
+			}
+		})
+	}
+}
+
+func TestQueryEscapesLiterals(t *testing.T) {
+	q := NewQuery(100, 60).InterfaceId(`eni-abc" or interfaceId != "x`)
+	want := `| filter interfaceId == "eni-abc\" or interfaceId != \"x"`
+	if !strings.Contains(q.GetQuery(), want) {
+		t.Errorf("query %q does not contain %q", q.GetQuery(), want)
+	}
+	if !q.Match(map[string]string{"interfaceId": `eni-abc" or interfaceId != "x`}) || q.Match(map[string]string{"interfaceId": "x"}) {
+		t.Error("unexpected match result for escaped literal")
+	}
 }
 
 func TestChainedFiltersJoinedWithNewlines(t *testing.T) {
@@ -68,7 +133,7 @@ func TestChainedFiltersJoinedWithNewlines(t *testing.T) {
 		"fields ",
 		`| filter logStatus != "NODATA"`,
 		`| filter action == "ACCEPT"`,
-		`| filter srcPort == "22"`,
+		`| filter srcPort == 22`,
 		`| sort @timestamp desc`,
 	}
 	for _, c := range wantClauses {
@@ -96,3 +161,147 @@ func TestQueryBranchingDoesNotAlias(t *testing.T) {
 		t.Errorf("base mutated by branches: %q", base.GetQuery())
 	}
 }
+
+func TestQueryMatch(t *testing.T) {
+	row := map[string]string{
+		"interfaceId":      "eni-abc",
+		"srcAddr":          "10.0.0.1",
+		"dstAddr":          "10.0.0.2",
+		"srcPort":          "45678",
+		"dstPort":          "443",
+		"protocol":         "6",
+		"action":           "ACCEPT",
+		"flowDirection":    "egress",
+		"logStatus":        "OK",
+		"subnetId":         "subnet-abc",
+		"pktDstAwsService": "S3",
+	}
+
+	tests := []struct {
+		name string
+		q    Query
+		want bool
+	}{
+		{"no filters", NewQuery(100, 60), true},
+		{"matching chain", NewQuery(100, 60).NoNoData().NoSkipData().Egress().Accept().Protocol("tcp").Port(443), true},
+		{"address matches destination", NewQuery(100, 60).Address("10.0.0.2"), true},
+		{"interface id", NewQuery(100, 60).InterfaceId("eni-abc"), true},
+		{"subnet id", NewQuery(100, 60).SubnetId("subnet-abc"), true},
+		{"aws service", NewQuery(100, 60).AwsService("s3"), true},
+		{"other aws service", NewQuery(100, 60).AwsService("DYNAMODB"), false},
+		{"ingress", NewQuery(100, 60).Ingress(), false},
+		{"reject", NewQuery(100, 60).Reject(), false},
+		{"udp", NewQuery(100, 60).Protocol("udp"), false},
+		{"source port", NewQuery(100, 60).SourcePort(443), false},
+		{"one filter does not match", NewQuery(100, 60).Accept().DestinationAddress("10.0.0.3"), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.q.Match(row); got != tc.want {
+				t.Errorf("Match() = %v, want %v (query %q)", got, tc.want, tc.q.GetQuery())
+			}
+		})
+	}
+}
+
+func TestQueryExclusions(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(Query) Query
+		want string
+	}{
+		{"NotInterfaceId", func(q Query) Query { return q.NotInterfaceId("eni-abc") }, `| filter not (interfaceId == "eni-abc")`},
+		{"NotProtocols", func(q Query) Query { return q.NotProtocols("udp") }, `| filter not (protocol == 17)`},
+		{"NotPorts", func(q Query) Query { return q.NotPorts(Ports{{443, 443}}) }, `| filter not (srcPort == 443 or dstPort == 443)`},
+		{"NotAddress", func(q Query) Query { return q.NotAddress("10.0.1.0/24") }, `| filter not (isIpv4InSubnet(srcAddr, "10.0.1.0/24") or isIpv4InSubnet(pktSrcAddr, "10.0.1.0/24") or isIpv4InSubnet(dstAddr, "10.0.1.0/24") or isIpv4InSubnet(pktDstAddr, "10.0.1.0/24"))`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.fn(NewQuery(100, 60)).GetQuery()
+			if !strings.Contains(got, tc.want) {
+				t.Errorf("query missing clause\n  got:  %q\n  want: %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestQueryExclusionsMatch(t *testing.T) {
+	healthCheck := map[string]string{"interfaceId": "eni-abc", "srcAddr": "10.0.1.10", "dstAddr": "10.0.2.20", "srcPort": "45678", "dstPort": "443", "protocol": "6"}
+	ntp := map[string]string{"interfaceId": "eni-abc", "srcAddr": "10.0.2.20", "dstAddr": "169.254.169.123", "srcPort": "123", "dstPort": "123", "protocol": "17"}
+
+	q := NewQuery(100, 60).NotAddress("10.0.1.0/24").NotProtocols("udp")
+	if q.Match(healthCheck) {
+		t.Error("health check from excluded subnet should not match")
+	}
+	if q.Match(ntp) {
+		t.Error("excluded protocol should not match")
+	}
+	if !NewQuery(100, 60).NotPorts(Ports{{123, 123}}).Match(healthCheck) {
+		t.Error("row with different ports should match")
+	}
+	if NewQuery(100, 60).NotInterfaceId("eni-abc").Match(ntp) {
+		t.Error("excluded interface should not match")
+	}
+}
+
+func TestQueryInsights(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline string
+		want     string
+	}{
+		{"appended stage", "filter dstPort like /^80/", "fields @timestamp"},
+		{"appended pipeline", "| parse @message '* *' as a, b | dedup srcAddr", "| parse @message '* *' as a, b | dedup srcAddr"},
+		{"replaced fields", "fields @timestamp, srcAddr | dedup srcAddr", "fields @timestamp, srcAddr\n| dedup srcAddr"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			q := NewQuery(100, 60).Insights(tc.pipeline)
+			if !q.IsRaw() {
+				t.Error("query should be raw")
+			}
+			if !strings.Contains(q.GetQuery(), tc.want) {
+				t.Errorf("query %q does not contain %q", q.GetQuery(), tc.want)
+			}
+		})
+	}
+
+	q := NewQuery(100, 60).Insights("filter action = 'REJECT'")
+	if got := strings.Split(q.GetQuery(), "\n")[1]; got != "| filter action = 'REJECT'" {
+		t.Errorf("stage without pipe: got %q", got)
+	}
+	if q.HasStage("sort") {
+		t.Error("query should not have sort stage")
+	}
+	if !q.Sort().HasStage("sort") || !NewQuery(100, 60).Insights("| stats count(*) by srcAddr").HasStage("stats") {
+		t.Error("query should have sort and stats stages")
+	}
+}
+
+func TestQueryIsMergeable(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline string
+		want     bool
+	}{
+		{"no pipeline", "", true},
+		{"filter", "filter dstPort like /^80/", true},
+		{"parse", "| parse @message '* *' as a, b", true},
+		{"stats", "| stats count(*) by srcAddr", false},
+		{"sort", "| sort bytes desc", false},
+		{"dedup", "fields @timestamp, srcAddr | dedup srcAddr", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// generated sort stage does not make the query unmergeable
+			q := NewQuery(100, 60).Insights(tc.pipeline).Sort()
+			if got := q.IsMergeable(); got != tc.want {
+				t.Errorf("IsMergeable() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,89 @@
 package query
 
-import "strconv"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tcpFlagBitByName are TCP flags recorded by flow logs, other flags (PSH, URG, ...) are not captured by AWS. Flags are
+// OR-ed for the aggregation interval, e.g. SYN-ACK is 18
+var tcpFlagBitByName = map[string]int{
+	"FIN": 1,
+	"SYN": 2,
+	"RST": 4,
+	"ACK": 16,
+}
+
+// TcpFlags is TCP flags filter, flags in set have to be present and flags in unset have to be absent
+type TcpFlags struct {
+	set   int
+	unset int
+}
+
+// ParseTcpFlags parses comma separated TCP flag names, flag prefixed with '!' has to be absent e.g. 'SYN,!ACK'
+func ParseTcpFlags(in string) (TcpFlags, error) {
+	var flags TcpFlags
+	for _, v := range strings.Split(in, ",") {
+		v = strings.ToUpper(strings.TrimSpace(v))
+		if v == "" {
+			continue
+		}
+		negate := strings.HasPrefix(v, "!")
+		name := strings.TrimPrefix(v, "!")
+		bit, ok := tcpFlagBitByName[name]
+		if !ok {
+			return TcpFlags{}, fmt.Errorf("invalid tcp flag %q, supported flags: FIN, SYN, RST, ACK", name)
+		}
+		if negate {
+			flags.unset |= bit
+			continue
+		}
+		flags.set |= bit
+	}
+
+	if flags.set == 0 && flags.unset == 0 {
+		return TcpFlags{}, errors.New("no tcp flags")
+	}
+	if flags.set&flags.unset != 0 {
+		return TcpFlags{}, fmt.Errorf("tcp flags %q are both set and unset", in)
+	}
+	return flags, nil
+}
+
+// values returns all tcpFlags field values that match the flags
+func (f TcpFlags) values() []int {
+	var mask int
+	for _, bit := range tcpFlagBitByName {
+		mask |= bit
+	}
+
+	var out []int
+	for v := 0; v <= mask; v++ {
+		// only combinations of flags recorded by flow logs
+		if v&^mask != 0 {
+			continue
+		}
+		if v&f.set == f.set && v&f.unset == 0 {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// tcpFlagsFilter returns filter expression with OR-ed bitmask values matching the flags
+func tcpFlagsFilter(flags TcpFlags) Expr {
+	var values []Value
+	for _, v := range flags.values() {
+		values = append(values, NumberValue(v))
+	}
+
+	if len(values) == 1 {
+		return Compare("tcpFlags", Eq, values[0])
+	}
+	return In("tcpFlags", values...)
+}
 
 func ToTcpFlagNames(in string) []string {
 	// tcp flags do not have to be set, do not return error
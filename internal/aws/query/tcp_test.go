@@ -33,3 +33,45 @@ func TestToTcpFlagNames(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTcpFlagsInvalid(t *testing.T) {
+	for _, in := range []string{"", "PSH", "SYN,!SYN", "SYN,FOO"} {
+		if _, err := ParseTcpFlags(in); err == nil {
+			t.Errorf("ParseTcpFlags(%q) expected error", in)
+		}
+	}
+}
+
+func TestTcpFlagsFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		match   string
+		noMatch string
+	}{
+		{"syn only", "SYN,!ACK", "tcpFlags in [2, 3, 6, 7]", "2", "18"},
+		{"syn-ack", "syn,ack", "tcpFlags in [18, 19, 22, 23]", "19", "2"},
+		{"rst", "RST", "tcpFlags in [4, 5, 6, 7, 20, 21, 22, 23]", "20", "-"},
+		{"single value", "SYN,!ACK,!FIN,!RST", `tcpFlags == 2`, "2", "3"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			flags, err := ParseTcpFlags(tc.in)
+			if err != nil {
+				t.Fatalf("ParseTcpFlags(%q): %v", tc.in, err)
+			}
+			e := tcpFlagsFilter(flags)
+			if got := e.Insights(); got != tc.want {
+				t.Errorf("tcpFlagsFilter(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+			if !e.Match(map[string]string{"tcpFlags": tc.match}) {
+				t.Errorf("expression does not match %q", tc.match)
+			}
+			if e.Match(map[string]string{"tcpFlags": tc.noMatch}) {
+				t.Errorf("expression matches %q", tc.noMatch)
+			}
+		})
+	}
+}
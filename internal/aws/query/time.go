@@ -1,6 +1,16 @@
 package query
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InsightsTimeLayout is format of Logs Insights @timestamp field and bin function results, records that are not
+// returned by Logs Insights (live tail, exported files) use the same format
+const InsightsTimeLayout = "2006-01-02 15:04:05.000"
 
 func ToTime(in string) string {
 	// in  - 2024-12-04 14:50:07.000
@@ -11,3 +21,69 @@ func ToTime(in string) string {
 	}
 	return t.Format("15:04:05")
 }
+
+// UnixToTime takes flow log start or end field (unix seconds) and returns time in the same format as ToTime
+func UnixToTime(in string) string {
+	sec, err := strconv.ParseInt(in, 10, 64)
+	if err != nil {
+		return ""
+	}
+	return time.Unix(sec, 0).UTC().Format("15:04:05")
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	time.DateOnly,
+}
+
+var durationUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// ParseTime parses absolute time (RFC3339, date with optional time in local timezone) or relative duration ago e.g.
+// '-3h', '2d', '90m' (sign is optional, duration is always in the past). 'now' returns supplied now time.
+func ParseTime(in string, now time.Time) (time.Time, error) {
+	in = strings.TrimSpace(in)
+	if in == "" {
+		return time.Time{}, errors.New("empty time")
+	}
+	if strings.EqualFold(in, "now") {
+		return now, nil
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, in, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	if d, ok := parseDuration(in); ok {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected RFC3339, date (2006-01-02 [15:04[:05]]) or relative duration (-3h, 2d)", in)
+}
+
+// parseDuration parses [-]<number><unit> where unit is one of s, m, h, d, w
+func parseDuration(in string) (time.Duration, bool) {
+	in = strings.TrimPrefix(in, "-")
+	if len(in) < 2 {
+		return 0, false
+	}
+	unit, ok := durationUnits[in[len(in)-1:]]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(in[:len(in)-1])
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
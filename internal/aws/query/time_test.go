@@ -1,6 +1,9 @@
 package query
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestToTime(t *testing.T) {
 	tests := []struct {
@@ -24,6 +27,26 @@ func TestToTime(t *testing.T) {
 	}
 }
 
+func TestUnixToTime(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"valid", "1733323807", "14:50:07"},
+		{"empty string", "", ""},
+		{"no data", "-", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := UnixToTime(tc.in); got != tc.want {
+				t.Errorf("UnixToTime(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
 func TestToPathName(t *testing.T) {
 	tests := []struct {
 		in   string
@@ -44,3 +67,46 @@ func TestToPathName(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 12, 4, 14, 50, 7, 0, time.UTC)
+	tests := []struct {
+		name string
+		in   string
+		want time.Time
+	}{
+		{"now", "now", now},
+		{"rfc3339", "2024-12-03T14:00:00Z", time.Date(2024, 12, 3, 14, 0, 0, 0, time.UTC)},
+		{"rfc3339 with offset", "2024-12-03T14:00:00+01:00", time.Date(2024, 12, 3, 13, 0, 0, 0, time.UTC)},
+		{"date and time", "2024-12-03 14:20", time.Date(2024, 12, 3, 14, 20, 0, 0, time.UTC)},
+		{"date and time with seconds", "2024-12-03 14:20:30", time.Date(2024, 12, 3, 14, 20, 30, 0, time.UTC)},
+		{"date only", "2024-12-03", time.Date(2024, 12, 3, 0, 0, 0, 0, time.UTC)},
+		{"negative hours", "-3h", now.Add(-3 * time.Hour)},
+		{"days without sign", "2d", now.Add(-48 * time.Hour)},
+		{"minutes", "-90m", now.Add(-90 * time.Minute)},
+		{"weeks", "1w", now.Add(-7 * 24 * time.Hour)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseTime(tc.in, now)
+			if err != nil {
+				t.Fatalf("ParseTime(%q) unexpected error: %v", tc.in, err)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("ParseTime(%q) = %v, want %v", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeInvalid(t *testing.T) {
+	now := time.Now()
+	for _, in := range []string{"", "yesterday", "3x", "h", "-", "2024-13-01", "--3h"} {
+		t.Run(in, func(t *testing.T) {
+			if _, err := ParseTime(in, now); err == nil {
+				t.Errorf("ParseTime(%q) expected error", in)
+			}
+		})
+	}
+}
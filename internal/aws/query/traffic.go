@@ -0,0 +1,19 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TrafficTypes are values of the flow log type field
+var TrafficTypes = []string{"IPv4", "IPv6", "EFA"}
+
+// ParseTrafficType returns traffic type (type field value) for case-insensitive input e.g. ipv6 -> IPv6
+func ParseTrafficType(in string) (string, error) {
+	for _, t := range TrafficTypes {
+		if strings.EqualFold(t, in) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("invalid traffic type %q, supported types: %s", in, strings.Join(TrafficTypes, ", "))
+}
@@ -0,0 +1,29 @@
+package query
+
+import "testing"
+
+func TestParseTrafficType(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"IPv4", "IPv4", false},
+		{"ipv6", "IPv6", false},
+		{"efa", "EFA", false},
+		{"ipv5", "", true},
+		{"", "", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseTrafficType(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseTrafficType(%q) error = %v, wantErr %v", tc.in, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("ParseTrafficType(%q) = %q, want %q", tc.in, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,566 @@
+package query
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// WhereError is error of the where expression, position is byte offset of the invalid token in the input
+type WhereError struct {
+	Input string
+	Pos   int
+	Msg   string
+}
+
+func (e WhereError) Error() string {
+	return fmt.Sprintf("%s at position %d\n  %s\n  %s^", e.Msg, e.Pos+1, e.Input, strings.Repeat(" ", e.Pos))
+}
+
+// whereFieldKind determines how the field values are parsed and which operators can be used
+type whereFieldKind int
+
+const (
+	stringField whereFieldKind = iota
+	numberField
+	addressField
+)
+
+var addressFields = []string{"srcAddr", "dstAddr", "pktSrcAddr", "pktDstAddr"}
+
+var numberFields = []string{"version", "srcPort", "dstPort", "protocol", "packets", "bytes", "start", "end", "tcpFlags", "trafficPath"}
+
+// derivedFields are fields displayed in the table (ni address, ni port, address and port), that depend on flow
+// direction. Value is ingress and egress (or unknown direction) field.
+var derivedFields = map[string][2]string{
+	"ni-addr":     {"dstAddr", "srcAddr"},
+	"ni-port":     {"dstPort", "srcPort"},
+	"remote-addr": {"srcAddr", "dstAddr"},
+	"remote-port": {"srcPort", "dstPort"},
+}
+
+// ParseWhere parses filter expression e.g. 'dstPort in (22, 3389) and not srcAddr in 10.0.0.0/8 or action = REJECT'.
+// Expression consists of comparisons (=, !=, <, <=, >, >=, in, not in) of field and value, combined with and, or, not
+// and parentheses. Fields are Logs Insights flow log fields (dstPort), log format fields (dstport) and fields derived
+// from flow direction (direction, ni-addr, ni-port, remote-addr, remote-port). Address fields accept IPs and CIDRs,
+// number fields accept port ranges (1024-65535) in lists and protocol accepts keywords (tcp).
+func ParseWhere(in string) (Expr, error) {
+	tokens, err := lexWhere(in)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &whereParser{input: in, tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "unexpected %s, expected and, or", t)
+	}
+	return e, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenOperator
+	tokenOpen
+	tokenClose
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// isKeyword returns true if the token is the (case-insensitive) keyword
+func (t token) isKeyword(keyword string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.text, keyword)
+}
+
+func isWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(`()[],=!<>"'`, r)
+}
+
+func lexWhere(in string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(in); {
+		r := rune(in[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == '[':
+			tokens = append(tokens, token{kind: tokenOpen, text: string(r), pos: i})
+			i++
+		case r == ')' || r == ']':
+			tokens = append(tokens, token{kind: tokenClose, text: string(r), pos: i})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: i})
+			i++
+		case strings.ContainsRune("=!<>", r):
+			op := string(r)
+			if i+1 < len(in) && in[i+1] == '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, WhereError{Input: in, Pos: i, Msg: `invalid operator "!", use != or not`}
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: i})
+			i += len(op)
+		case r == '"' || r == '\'':
+			s, n, err := unquote(in[i:])
+			if err != nil {
+				return nil, WhereError{Input: in, Pos: i, Msg: err.Error()}
+			}
+			tokens = append(tokens, token{kind: tokenString, text: s, pos: i})
+			i += n
+		default:
+			start := i
+			for i < len(in) && isWordRune(rune(in[i])) {
+				i++
+			}
+			if i == start {
+				return nil, WhereError{Input: in, Pos: i, Msg: fmt.Sprintf("unexpected character %q", in[i])}
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: in[start:i], pos: start})
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(in)}), nil
+}
+
+// unquote returns quoted string (quote is the first character) and number of consumed bytes, backslash escapes the
+// next character
+func unquote(in string) (string, int, error) {
+	quote := in[0]
+	var b strings.Builder
+	for i := 1; i < len(in); i++ {
+		switch in[i] {
+		case '\\':
+			if i+1 < len(in) {
+				i++
+				b.WriteByte(in[i])
+			}
+		case quote:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(in[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+type whereParser struct {
+	input  string
+	tokens []token
+	pos    int
+}
+
+func (p *whereParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *whereParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *whereParser) errorf(t token, format string, a ...any) error {
+	return WhereError{Input: p.input, Pos: t.pos, Msg: fmt.Sprintf(format, a...)}
+}
+
+func (p *whereParser) parseOr() (Expr, error) {
+	e, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	exprs := []Expr{e}
+	for p.peek().isKeyword("or") {
+		p.next()
+		e, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return Or(exprs...), nil
+}
+
+func (p *whereParser) parseAnd() (Expr, error) {
+	e, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	exprs := []Expr{e}
+	for p.peek().isKeyword("and") {
+		p.next()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return And(exprs...), nil
+}
+
+func (p *whereParser) parseUnary() (Expr, error) {
+	if p.peek().isKeyword("not") {
+		p.next()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not(e), nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *whereParser) parsePrimary() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenOpen:
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.next(); c.kind != tokenClose {
+			return nil, p.errorf(c, "unexpected %s, expected closing parenthesis", c)
+		}
+		return e, nil
+	case tokenWord:
+		if slices.ContainsFunc([]string{"and", "or", "in"}, t.isKeyword) {
+			return nil, p.errorf(t, "unexpected %s, expected field name or opening parenthesis", t)
+		}
+		return p.parseComparison(t)
+	default:
+		return nil, p.errorf(t, "unexpected %s, expected field name or opening parenthesis", t)
+	}
+}
+
+// parseComparison parses operator and value(s) of the field
+func (p *whereParser) parseComparison(field token) (Expr, error) {
+	f, err := resolveWhereField(field.text)
+	if err != nil {
+		return nil, p.errorf(field, "%v", err)
+	}
+
+	op := p.next()
+	switch {
+	case op.kind == tokenOperator:
+		operator := Operator(op.text)
+		if operator == "=" {
+			operator = Eq
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return p.compile(f, op, operator, []token{value})
+	case op.isKeyword("in"):
+		values, err := p.parseValues()
+		if err != nil {
+			return nil, err
+		}
+		return p.compile(f, op, Eq, values)
+	case op.isKeyword("not"):
+		if in := p.next(); !in.isKeyword("in") {
+			return nil, p.errorf(in, "unexpected %s, expected in", in)
+		}
+		values, err := p.parseValues()
+		if err != nil {
+			return nil, err
+		}
+		e, err := p.compile(f, op, Eq, values)
+		if err != nil {
+			return nil, err
+		}
+		return Not(e), nil
+	default:
+		return nil, p.errorf(op, "unexpected %s, expected operator (=, !=, <, <=, >, >=, in, not in)", op)
+	}
+}
+
+func (p *whereParser) parseValue() (token, error) {
+	t := p.next()
+	if t.kind != tokenWord && t.kind != tokenString {
+		return token{}, p.errorf(t, "unexpected %s, expected value", t)
+	}
+	return t, nil
+}
+
+// parseValues parses single value, or list of values in parentheses or brackets
+func (p *whereParser) parseValues() ([]token, error) {
+	if p.peek().kind != tokenOpen {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return []token{value}, nil
+	}
+
+	p.next()
+	var values []token
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		t := p.next()
+		if t.kind == tokenClose {
+			return values, nil
+		}
+		if t.kind != tokenComma {
+			return nil, p.errorf(t, "unexpected %s, expected comma or closing parenthesis", t)
+		}
+	}
+}
+
+// whereField is resolved field of the where expression
+type whereField struct {
+	name    string
+	kind    whereFieldKind
+	derived [2]string
+}
+
+func resolveWhereField(in string) (whereField, error) {
+	if strings.EqualFold(in, "direction") {
+		in = "flowDirection"
+	}
+	if derived, ok := derivedFields[strings.ToLower(in)]; ok {
+		return whereField{name: strings.ToLower(in), kind: fieldKind(derived[0]), derived: derived}, nil
+	}
+
+	name, ok := fieldByLogFormatField[strings.ToLower(in)]
+	if !ok {
+		for _, field := range slices.Concat(Fields, fieldNames()) {
+			if strings.EqualFold(field, in) {
+				name, ok = field, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return whereField{}, fmt.Errorf("unknown field %q", in)
+	}
+	return whereField{name: name, kind: fieldKind(name)}, nil
+}
+
+// fieldNames returns Logs Insights names of all flow log fields
+func fieldNames() []string {
+	var out []string
+	for _, v := range fieldByLogFormatField {
+		out = append(out, v)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func fieldKind(field string) whereFieldKind {
+	switch {
+	case slices.Contains(addressFields, field):
+		return addressField
+	case slices.Contains(numberFields, field):
+		return numberField
+	default:
+		return stringField
+	}
+}
+
+// compile returns expression for the field, operator and values. Multiple values (in) are OR-ed
+func (p *whereParser) compile(f whereField, opToken token, op Operator, values []token) (Expr, error) {
+	if f.derived[0] == "" {
+		return p.compileField(f.name, f.kind, opToken, op, values)
+	}
+
+	// ingress flow logs have interface address in destination, egress (and unknown direction) in source
+	ingress, err := p.compileField(f.derived[0], f.kind, opToken, op, values)
+	if err != nil {
+		return nil, err
+	}
+	egress, err := p.compileField(f.derived[1], f.kind, opToken, op, values)
+	if err != nil {
+		return nil, err
+	}
+	isIngress := Compare("flowDirection", Eq, StringValue("ingress"))
+	return Or(And(isIngress, ingress), And(Not(isIngress), egress)), nil
+}
+
+func (p *whereParser) compileField(field string, kind whereFieldKind, opToken token, op Operator, values []token) (Expr, error) {
+	switch kind {
+	case addressField:
+		return p.compileAddress(field, opToken, op, values)
+	case numberField:
+		return p.compileNumber(field, opToken, op, values)
+	default:
+		return p.compileString(field, opToken, op, values)
+	}
+}
+
+func (p *whereParser) compileAddress(field string, opToken token, op Operator, values []token) (Expr, error) {
+	if op != Eq && op != Ne {
+		return nil, p.errorf(opToken, "operator %s cannot be used with address field %s", opToken.text, field)
+	}
+
+	var exprs []Expr
+	for _, v := range values {
+		e, err := addressExpression(field, v.text)
+		if err != nil {
+			return nil, p.errorf(v, "%v", err)
+		}
+		exprs = append(exprs, e)
+	}
+	e := orOne(exprs)
+	if op == Ne {
+		return Not(e), nil
+	}
+	return e, nil
+}
+
+func (p *whereParser) compileNumber(field string, opToken token, op Operator, values []token) (Expr, error) {
+	var singles []Value
+	var ranges []Expr
+	for _, v := range values {
+		n, err := parseNumber(field, v.text)
+		if err == nil {
+			singles = append(singles, NumberValue(n))
+			continue
+		}
+		// range of numbers (1024-65535), protocol keywords can contain hyphen as well (ipv6-icmp)
+		from, to, isRange := strings.Cut(v.text, "-")
+		if !isRange || v.kind != tokenWord || !isDigits(from) || !isDigits(to) {
+			return nil, p.errorf(v, "%v", err)
+		}
+		if opToken.kind == tokenOperator {
+			return nil, p.errorf(v, "range can be used only with in operator")
+		}
+		fromN, toN, err := parseRange(field, from, to)
+		if err != nil {
+			return nil, p.errorf(v, "%v", err)
+		}
+		ranges = append(ranges, And(Compare(field, Ge, NumberValue(fromN)), Compare(field, Le, NumberValue(toN))))
+	}
+
+	if op != Eq {
+		return Compare(field, op, singles[0]), nil
+	}
+	var exprs []Expr
+	switch len(singles) {
+	case 0:
+	case 1:
+		exprs = append(exprs, Compare(field, Eq, singles[0]))
+	default:
+		exprs = append(exprs, In(field, singles...))
+	}
+	return orOne(append(exprs, ranges...)), nil
+}
+
+// parseRange parses range of numbers e.g. 1024-65535
+func parseRange(field, from, to string) (int, int, error) {
+	fromN, err := parseNumber(field, from)
+	if err != nil {
+		return 0, 0, err
+	}
+	toN, err := parseNumber(field, to)
+	if err != nil {
+		return 0, 0, err
+	}
+	if fromN > toN {
+		return 0, 0, fmt.Errorf("invalid range %s-%s, start is greater than end", from, to)
+	}
+	return fromN, toN, nil
+}
+
+// isDigits returns true if the value is not empty and has only digits
+func isDigits(in string) bool {
+	return in != "" && strings.Trim(in, "0123456789") == ""
+}
+
+// parseNumber parses number value, protocol field accepts protocol keywords as well
+func parseNumber(field, in string) (int, error) {
+	if field == "protocol" {
+		return ParseProtocol(in)
+	}
+	n, err := strconv.Atoi(in)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q, %s is number field", in, field)
+	}
+	if (field == "srcPort" || field == "dstPort") && (n < 0 || n > maxPort) {
+		return 0, fmt.Errorf("invalid port %q, port has to be number between 0 and %d", in, maxPort)
+	}
+	return n, nil
+}
+
+func (p *whereParser) compileString(field string, opToken token, op Operator, values []token) (Expr, error) {
+	if op != Eq && op != Ne {
+		return nil, p.errorf(opToken, "operator %s cannot be used with string field %s", opToken.text, field)
+	}
+
+	var strValues []Value
+	for _, v := range values {
+		s, err := normalizeValue(field, v.text)
+		if err != nil {
+			return nil, p.errorf(v, "%v", err)
+		}
+		strValues = append(strValues, StringValue(s))
+	}
+
+	if len(strValues) == 1 {
+		return Compare(field, op, strValues[0]), nil
+	}
+	return In(field, strValues...), nil
+}
+
+// normalizeValue validates and normalizes values of fields with known values, e.g. reject -> REJECT
+func normalizeValue(field, in string) (string, error) {
+	switch field {
+	case "action":
+		if v := strings.ToUpper(in); v == "ACCEPT" || v == "REJECT" {
+			return v, nil
+		}
+		return "", fmt.Errorf("invalid action %q, supported actions: ACCEPT, REJECT", in)
+	case "flowDirection":
+		if v := strings.ToLower(in); v == "ingress" || v == "egress" {
+			return v, nil
+		}
+		return "", fmt.Errorf("invalid direction %q, supported directions: ingress, egress", in)
+	case "type":
+		return ParseTrafficType(in)
+	case "logStatus", "pktSrcAwsService", "pktDstAwsService":
+		return strings.ToUpper(in), nil
+	}
+	return in, nil
+}
+
+// orOne returns the only expression, or expressions OR-ed
+func orOne(exprs []Expr) Expr {
+	if len(exprs) == 1 {
+		return exprs[0]
+	}
+	return Or(exprs...)
+}
@@ -0,0 +1,123 @@
+package query
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseWhere(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`action = reject`, `action == "REJECT"`},
+		{`dstport == 22`, `dstPort == 22`},
+		{`dstPort in (22, 3389)`, `dstPort in [22, 3389]`},
+		{`dstPort in [22, 1024-65535]`, `dstPort == 22 or (dstPort >= 1024 and dstPort <= 65535)`},
+		{`dstPort not in (22, 3389)`, `not (dstPort in [22, 3389])`},
+		{`bytes > 1000`, `bytes > 1000`},
+		{`protocol = tcp`, `protocol == 6`},
+		{`protocol = ipv6-icmp`, `protocol == 58`},
+		{`protocol in (tcp, ipv6-icmp, 1-2)`, `protocol in [6, 58] or (protocol >= 1 and protocol <= 2)`},
+		{`srcAddr in 10.0.0.0/8`, `isIpv4InSubnet(srcAddr, "10.0.0.0/8")`},
+		{`srcAddr != 10.0.0.1`, `not (srcAddr == "10.0.0.1")`},
+		{`srcAddr in (10.0.0.1, 2001:db8::/32)`, `srcAddr == "10.0.0.1" or isIpInSubnet(srcAddr, "2001:db8::/32")`},
+		{`direction = INGRESS`, `flowDirection == "ingress"`},
+		{`type = ipv6`, `type == "IPv6"`},
+		{`instanceId = "i-0\"1"`, `instanceId == "i-0\"1"`},
+		{
+			`dstPort in (22,3389) and (srcAddr in 0.0.0.0/0 and not srcAddr in 10.0.0.0/8) or action = REJECT`,
+			`(dstPort in [22, 3389] and isIpv4InSubnet(srcAddr, "0.0.0.0/0") and not (isIpv4InSubnet(srcAddr, "10.0.0.0/8"))) or action == "REJECT"`,
+		},
+		{
+			`ni-port = 22`,
+			`(flowDirection == "ingress" and dstPort == 22) or (not (flowDirection == "ingress") and srcPort == 22)`,
+		},
+		{
+			`remote-addr in 10.0.0.0/8`,
+			`(flowDirection == "ingress" and isIpv4InSubnet(srcAddr, "10.0.0.0/8")) or (not (flowDirection == "ingress") and isIpv4InSubnet(dstAddr, "10.0.0.0/8"))`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			e, err := ParseWhere(tc.in)
+			if err != nil {
+				t.Fatalf("ParseWhere() unexpected error: %v", err)
+			}
+			if err := Validate(e); err != nil {
+				t.Fatalf("Validate() unexpected error: %v", err)
+			}
+			if got := e.Insights(); got != tc.want {
+				t.Errorf("Insights() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseWhereMatch(t *testing.T) {
+	ingress := map[string]string{"flowDirection": "ingress", "srcAddr": "203.0.113.1", "dstAddr": "10.0.0.5", "srcPort": "50000", "dstPort": "22"}
+	egress := map[string]string{"flowDirection": "egress", "srcAddr": "10.0.0.5", "dstAddr": "203.0.113.1", "srcPort": "22", "dstPort": "50000"}
+
+	for _, in := range []string{`ni-port = 22`, `ni-addr = 10.0.0.5`, `remote-addr not in 10.0.0.0/8`, `remote-port >= 1024`} {
+		e, err := ParseWhere(in)
+		if err != nil {
+			t.Fatalf("ParseWhere(%q) unexpected error: %v", in, err)
+		}
+		if !e.Match(ingress) || !e.Match(egress) {
+			t.Errorf("ParseWhere(%q) expected to match both ingress and egress flow", in)
+		}
+	}
+}
+
+func TestParseWhereErrors(t *testing.T) {
+	tests := []struct {
+		in  string
+		msg string
+		pos int
+	}{
+		{`dstPort in (22, 3389`, "expected comma or closing parenthesis", 20},
+		{`foo = 1`, `unknown field "foo"`, 0},
+		{`action = REJECT and dstPort = x`, `invalid value "x"`, 30},
+		{`dstPort = 70000`, "invalid port", 10},
+		{`srcAddr < 10.0.0.1`, "operator < cannot be used", 8},
+		{`srcAddr = 10.0.0.300`, "invalid IP address", 10},
+		{`action = drop`, "invalid action", 9},
+		{`protocol = foo`, "unknown protocol", 11},
+		{`protocol = foo-bar`, "unknown protocol", 11},
+		{`protocol in (ipv6-foo)`, "unknown protocol", 13},
+		{`dstPort = 1024-65535`, "range can be used only with in operator", 10},
+		{`(action = REJECT`, "expected closing parenthesis", 16},
+		{`action = REJECT dstPort = 22`, "expected and, or", 16},
+		{`action ! REJECT`, `invalid operator "!"`, 7},
+		{`instanceId = "i-1`, "unterminated string", 13},
+		{`and`, "expected field name", 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			_, err := ParseWhere(tc.in)
+			if err == nil {
+				t.Fatal("ParseWhere() expected error")
+			}
+			werr, ok := err.(WhereError)
+			if !ok {
+				t.Fatalf("ParseWhere() error type %T, want WhereError", err)
+			}
+			if !strings.Contains(werr.Msg, tc.msg) {
+				t.Errorf("ParseWhere() error %q, want %q", werr.Msg, tc.msg)
+			}
+			if werr.Pos != tc.pos {
+				t.Errorf("ParseWhere() error position %d, want %d", werr.Pos, tc.pos)
+			}
+		})
+	}
+}
+
+func TestWhereErrorCaret(t *testing.T) {
+	_, err := ParseWhere(`dstPort = x`)
+	want := "invalid value \"x\", dstPort is number field at position 11\n  dstPort = x\n            ^"
+	if err == nil || err.Error() != want {
+		t.Errorf("ParseWhere() error %q, want %q", err, want)
+	}
+}
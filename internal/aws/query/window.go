@@ -0,0 +1,55 @@
+package query
+
+import "time"
+
+// Window is query time range. Logs Insights start and end times are in seconds and both are inclusive, window is
+// truncated to seconds as well, so adjacent windows do not overlap.
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+func NewWindow(start, end time.Time) Window {
+	return Window{
+		Start: start.Truncate(time.Second),
+		End:   end.Truncate(time.Second),
+	}
+}
+
+// seconds returns number of seconds in the window (both start and end inclusive)
+func (w Window) seconds() int64 {
+	return w.End.Unix() - w.Start.Unix() + 1
+}
+
+// Split splits window into n (or fewer, if the window is shorter than n seconds) adjacent windows
+func (w Window) Split(n int) []Window {
+	seconds := w.seconds()
+	if n < 1 || seconds < 1 {
+		return []Window{w}
+	}
+	if int64(n) > seconds {
+		n = int(seconds)
+	}
+
+	var out []Window
+	start := w.Start.Unix()
+	for i := range n {
+		// distribute remaining seconds across first windows
+		size := seconds / int64(n)
+		if int64(i) < seconds%int64(n) {
+			size++
+		}
+		out = append(out, Window{Start: time.Unix(start, 0), End: time.Unix(start+size-1, 0)})
+		start += size
+	}
+	return out
+}
+
+// Bisect splits window into two halves, returns false if the window cannot be split (is one second long)
+func (w Window) Bisect() (Window, Window, bool) {
+	windows := w.Split(2)
+	if len(windows) != 2 {
+		return w, Window{}, false
+	}
+	return windows[0], windows[1], true
+}
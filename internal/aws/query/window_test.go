@@ -0,0 +1,73 @@
+package query
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowSplit(t *testing.T) {
+	start := time.Date(2024, 12, 3, 14, 0, 0, 0, time.UTC)
+	w := NewWindow(start, start.Add(time.Hour-time.Second))
+
+	windows := w.Split(4)
+	if len(windows) != 4 {
+		t.Fatalf("expected 4 windows, got %d", len(windows))
+	}
+	if !windows[0].Start.Equal(w.Start) {
+		t.Errorf("first window start %v, want %v", windows[0].Start, w.Start)
+	}
+	if !windows[3].End.Equal(w.End) {
+		t.Errorf("last window end %v, want %v", windows[3].End, w.End)
+	}
+	for i := 1; i < len(windows); i++ {
+		if got := windows[i].Start.Sub(windows[i-1].End); got != time.Second {
+			t.Errorf("window %d does not follow previous window: gap %v", i, got)
+		}
+		if got := windows[i].End.Sub(windows[i].Start); got != 15*time.Minute-time.Second {
+			t.Errorf("window %d: unexpected size %v", i, got)
+		}
+	}
+}
+
+func TestWindowSplitUneven(t *testing.T) {
+	start := time.Date(2024, 12, 3, 14, 0, 0, 0, time.UTC)
+	// 10 seconds, inclusive
+	w := NewWindow(start, start.Add(9*time.Second))
+
+	windows := w.Split(3)
+	var total int64
+	for _, v := range windows {
+		total += v.seconds()
+	}
+	if total != 10 {
+		t.Errorf("split windows cover %d seconds, want 10", total)
+	}
+	if !windows[2].End.Equal(w.End) {
+		t.Errorf("last window end %v, want %v", windows[2].End, w.End)
+	}
+}
+
+func TestWindowSplitShorterThanN(t *testing.T) {
+	start := time.Date(2024, 12, 3, 14, 0, 0, 0, time.UTC)
+	w := NewWindow(start, start.Add(2*time.Second))
+
+	if got := len(w.Split(10)); got != 3 {
+		t.Errorf("expected 3 one second windows, got %d", got)
+	}
+}
+
+func TestWindowBisect(t *testing.T) {
+	start := time.Date(2024, 12, 3, 14, 0, 0, 0, time.UTC)
+
+	a, b, ok := NewWindow(start, start.Add(time.Minute)).Bisect()
+	if !ok {
+		t.Fatal("expected window to be bisected")
+	}
+	if !a.Start.Equal(start) || !b.End.Equal(start.Add(time.Minute)) || b.Start.Sub(a.End) != time.Second {
+		t.Errorf("unexpected halves %v %v", a, b)
+	}
+
+	if _, _, ok := NewWindow(start, start).Bisect(); ok {
+		t.Error("one second window should not be bisected")
+	}
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+const fileName = "config.yaml"
+
+// Config is flowlogs cli configuration stored in the user config directory (e.g. ~/.config/flowlogs/config.yaml)
+type Config struct {
+	Defaults      map[string]Defaults     `yaml:"defaults,omitempty"`
+	Presets       map[string]Preset       `yaml:"presets,omitempty"`
+	NoiseProfiles map[string]NoiseProfile `yaml:"noise_profiles,omitempty"`
+}
+
+// NoiseProfile is named set of exclusions (e.g. load balancer health checks, NTP) that can be applied to queries
+type NoiseProfile struct {
+	Addresses    []string `yaml:"addresses,omitempty"`
+	Ports        []string `yaml:"ports,omitempty"`
+	InterfaceIds []string `yaml:"interface_ids,omitempty"`
+	Protocols    []string `yaml:"protocols,omitempty"`
+}
+
+// Path returns config file path
+func Path() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("user config dir: %w", err)
+	}
+	return filepath.Join(dir, "flowlogs", fileName), nil
+}
+
+// Load loads config from the config file, empty config is returned if the file does not exist
+func Load() (Config, error) {
+	path, err := Path()
+	if err != nil {
+		return Config{}, err
+	}
+	return load(path)
+}
+
+func load(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Config{}, nil
+		}
+		return Config{}, fmt.Errorf("read config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return Config{}, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Save writes config to the config file, config directory is created if it does not exist
+func (c Config) Save() error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	return c.save(path)
+}
+
+func (c Config) save(path string) error {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshal config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	return nil
+}
+
+// NoiseProfileNames returns sorted noise profile names
+func (c Config) NoiseProfileNames() []string {
+	var names []string
+	for name := range c.NoiseProfiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// GetNoiseProfile returns noise profile by name or error if it does not exist
+func (c Config) GetNoiseProfile(name string) (NoiseProfile, error) {
+	profile, ok := c.NoiseProfiles[name]
+	if !ok {
+		return NoiseProfile{}, fmt.Errorf("noise profile %s not found", name)
+	}
+	return profile, nil
+}
+
+func (c Config) SetNoiseProfile(name string, profile NoiseProfile) Config {
+	if c.NoiseProfiles == nil {
+		c.NoiseProfiles = make(map[string]NoiseProfile)
+	}
+	c.NoiseProfiles[name] = profile
+	return c
+}
+
+func (c Config) DeleteNoiseProfile(name string) (Config, error) {
+	if _, ok := c.NoiseProfiles[name]; !ok {
+		return c, fmt.Errorf("noise profile %s not found", name)
+	}
+	delete(c.NoiseProfiles, name)
+	return c, nil
+}
+
+// Merge returns noise profile with exclusions from both profiles
+func (p NoiseProfile) Merge(other NoiseProfile) NoiseProfile {
+	return NoiseProfile{
+		Addresses:    append(slices.Clone(p.Addresses), other.Addresses...),
+		Ports:        append(slices.Clone(p.Ports), other.Ports...),
+		InterfaceIds: append(slices.Clone(p.InterfaceIds), other.InterfaceIds...),
+		Protocols:    append(slices.Clone(p.Protocols), other.Protocols...),
+	}
+}
+
+func (p NoiseProfile) IsEmpty() bool {
+	return len(p.Addresses) == 0 && len(p.Ports) == 0 && len(p.InterfaceIds) == 0 && len(p.Protocols) == 0
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	cfg, err := load(filepath.Join(t.TempDir(), "missing", fileName))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.NoiseProfiles) != 0 {
+		t.Errorf("expected empty config, got %+v", cfg)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flowlogs", fileName)
+	profile := NoiseProfile{Addresses: []string{"10.0.1.0/24"}, Ports: []string{"123"}, Protocols: []string{"udp"}}
+
+	cfg := Config{}.SetNoiseProfile("alb", profile)
+	if err := cfg.save(path); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	loaded, err := load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	got, err := loaded.GetNoiseProfile("alb")
+	if err != nil {
+		t.Fatalf("get noise profile: %v", err)
+	}
+	if !reflect.DeepEqual(got, profile) {
+		t.Errorf("loaded profile %+v, want %+v", got, profile)
+	}
+}
+
+func TestNoiseProfiles(t *testing.T) {
+	cfg := Config{}.
+		SetNoiseProfile("ntp", NoiseProfile{Ports: []string{"123"}}).
+		SetNoiseProfile("alb", NoiseProfile{Addresses: []string{"10.0.1.0/24"}})
+
+	if got, want := cfg.NoiseProfileNames(), []string{"alb", "ntp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("names: got %v, want %v", got, want)
+	}
+
+	cfg, err := cfg.DeleteNoiseProfile("ntp")
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := cfg.GetNoiseProfile("ntp"); err == nil {
+		t.Error("expected deleted profile to be missing")
+	}
+	if _, err := cfg.DeleteNoiseProfile("ntp"); err == nil {
+		t.Error("expected error when deleting missing profile")
+	}
+}
+
+func TestNoiseProfileMerge(t *testing.T) {
+	a := NoiseProfile{Addresses: []string{"10.0.1.0/24"}, Ports: []string{"123"}}
+	b := NoiseProfile{Addresses: []string{"10.0.2.0/24"}, Protocols: []string{"udp"}}
+
+	got := a.Merge(b)
+	want := NoiseProfile{Addresses: []string{"10.0.1.0/24", "10.0.2.0/24"}, Ports: []string{"123"}, Protocols: []string{"udp"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merge: got %+v, want %+v", got, want)
+	}
+	if len(a.Addresses) != 1 {
+		t.Errorf("merge modified original profile: %+v", a)
+	}
+	if (NoiseProfile{}).IsEmpty() != true || got.IsEmpty() {
+		t.Error("unexpected IsEmpty result")
+	}
+}
+
+func TestPresets(t *testing.T) {
+	cfg := Config{}.
+		SetPreset("ssh-rejects", Preset{"dst-port": "22", "reject": "true"}).
+		SetPreset("dns", Preset{"port": "53", "protocol": "udp"})
+
+	if got, want := cfg.PresetNames(), []string{"dns", "ssh-rejects"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("names: got %v, want %v", got, want)
+	}
+	preset, err := cfg.GetPreset("ssh-rejects")
+	if err != nil {
+		t.Fatalf("get preset: %v", err)
+	}
+	if got, want := preset.String(), "--dst-port 22 --reject"; got != want {
+		t.Errorf("preset string: got %q, want %q", got, want)
+	}
+
+	cfg, err = cfg.DeletePreset("dns")
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := cfg.GetPreset("dns"); err == nil {
+		t.Error("expected deleted preset to be missing")
+	}
+}
+
+func TestDefaultsFlags(t *testing.T) {
+	pretty := false
+	cfg := Config{Defaults: map[string]Defaults{
+		"prod": {Region: "eu-west-2", Limit: 500, Pretty: &pretty, PricePerGB: 0.0076, MaxScanGB: 50},
+	}}
+
+	want := map[string]string{"region": "eu-west-2", "limit": "500", "pretty": "false", "price-per-gb": "0.0076", "max-scan-gb": "50"}
+	if got := cfg.GetDefaults("prod").Flags(); !reflect.DeepEqual(got, want) {
+		t.Errorf("prod defaults: got %v, want %v", got, want)
+	}
+	if got := cfg.GetDefaults("dev").Flags(); len(got) != 0 {
+		t.Errorf("dev defaults: got %v, want empty", got)
+	}
+}
+
+func TestAWSProfile(t *testing.T) {
+	t.Setenv("AWS_PROFILE", "")
+	if got := AWSProfile(); got != DefaultProfile {
+		t.Errorf("got %q, want %q", got, DefaultProfile)
+	}
+	t.Setenv("AWS_PROFILE", "prod")
+	if got := AWSProfile(); got != "prod" {
+		t.Errorf("got %q, want prod", got)
+	}
+}
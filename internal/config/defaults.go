@@ -0,0 +1,58 @@
+package config
+
+import (
+	"os"
+	"strconv"
+)
+
+// DefaultProfile is used for defaults when AWS_PROFILE is not set
+const DefaultProfile = "default"
+
+// Defaults are flag default values for AWS profile
+type Defaults struct {
+	Region  string `yaml:"region,omitempty"`
+	Limit   int    `yaml:"limit,omitempty"`
+	Pretty  *bool  `yaml:"pretty,omitempty"`
+	Minutes int    `yaml:"minutes,omitempty"`
+	// PricePerGB is Logs Insights price per GB scanned, used for query cost estimate
+	PricePerGB float64 `yaml:"price-per-gb,omitempty"`
+	// MaxScanGB is the maximum number of GB queries can scan
+	MaxScanGB float64 `yaml:"max-scan-gb,omitempty"`
+}
+
+// AWSProfile returns AWS profile name from AWS_PROFILE environment variable, or default profile
+func AWSProfile() string {
+	if profile := os.Getenv("AWS_PROFILE"); profile != "" {
+		return profile
+	}
+	return DefaultProfile
+}
+
+// GetDefaults returns defaults for AWS profile, empty defaults are returned if the profile does not have any
+func (c Config) GetDefaults(profile string) Defaults {
+	return c.Defaults[profile]
+}
+
+// Flags returns defaults keyed by flag name, only values that are set are returned
+func (d Defaults) Flags() map[string]string {
+	out := make(map[string]string)
+	if d.Region != "" {
+		out["region"] = d.Region
+	}
+	if d.Limit != 0 {
+		out["limit"] = strconv.Itoa(d.Limit)
+	}
+	if d.Pretty != nil {
+		out["pretty"] = strconv.FormatBool(*d.Pretty)
+	}
+	if d.Minutes != 0 {
+		out["minutes"] = strconv.Itoa(d.Minutes)
+	}
+	if d.PricePerGB != 0 {
+		out["price-per-gb"] = strconv.FormatFloat(d.PricePerGB, 'f', -1, 64)
+	}
+	if d.MaxScanGB != 0 {
+		out["max-scan-gb"] = strconv.FormatFloat(d.MaxScanGB, 'f', -1, 64)
+	}
+	return out
+}
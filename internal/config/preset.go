@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Preset is named query, key is query flag name (without dashes) and value is flag value
+type Preset map[string]string
+
+// String returns preset as command line flags e.g. '--dst-port 22 --reject'
+func (p Preset) String() string {
+	var names []string
+	for name := range p {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var out []string
+	for _, name := range names {
+		if p[name] == "true" {
+			out = append(out, fmt.Sprintf("--%s", name))
+			continue
+		}
+		out = append(out, fmt.Sprintf("--%s %s", name, p[name]))
+	}
+	return strings.Join(out, " ")
+}
+
+// PresetNames returns sorted preset names
+func (c Config) PresetNames() []string {
+	var names []string
+	for name := range c.Presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// GetPreset returns preset by name or error if it does not exist
+func (c Config) GetPreset(name string) (Preset, error) {
+	preset, ok := c.Presets[name]
+	if !ok {
+		return nil, fmt.Errorf("preset %s not found", name)
+	}
+	return preset, nil
+}
+
+func (c Config) SetPreset(name string, preset Preset) Config {
+	if c.Presets == nil {
+		c.Presets = make(map[string]Preset)
+	}
+	c.Presets[name] = preset
+	return c
+}
+
+func (c Config) DeletePreset(name string) (Config, error) {
+	if _, ok := c.Presets[name]; !ok {
+		return c, fmt.Errorf("preset %s not found", name)
+	}
+	delete(c.Presets, name)
+	return c, nil
+}
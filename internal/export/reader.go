@@ -0,0 +1,224 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pete911/flowlogs/internal/aws/query"
+)
+
+// StdinPath reads records from standard input, e.g. records pasted into terminal
+const StdinPath = "-"
+
+// maxLineSize is maximum size of flow log record, V7 records with ECS ARNs can be long
+const maxLineSize = 1024 * 1024
+
+// logFormats are formats used for records without header line, format is selected by number of record values
+var logFormats = []query.FlowLogFields{
+	query.FlowLogFieldsDefault,
+	query.FlowLogFieldsV2V5,
+	append(append(query.FlowLogFields{}, query.FlowLogFieldsV2V5...), query.FlowLogFieldsV7...),
+}
+
+// hiveFieldByKey maps hive partition keys of S3 flow log delivery (e.g. aws-region=eu-west-2) to record fields, that
+// are set if the record does not have them
+var hiveFieldByKey = map[string]string{
+	"aws-account-id": "accountId",
+	"aws-region":     "region",
+}
+
+// Result is flow log records read from files, records are keyed by Logs Insights field names
+type Result struct {
+	Records []map[string]string
+	Files   int
+	Bytes   int64
+}
+
+// Reader reads flow log records exported to files (S3 delivery, gzip archives, pasted records)
+type Reader struct {
+	logFormat query.FlowLogFields
+}
+
+// NewReader returns reader, log format (e.g. '${version} ${srcaddr} ...') is used for records without header line.
+// Empty log format detects default, cli (V2-V5) or cli with ECS (V2-V7) format by number of record values.
+func NewReader(logFormat string) Reader {
+	if strings.TrimSpace(logFormat) == "" {
+		return Reader{}
+	}
+	return Reader{logFormat: query.ParseLogFormat(logFormat)}
+}
+
+// Read reads records from files and directories (recursively, including hive partitioned S3 layout), gzip files are
+// decompressed. Records get @timestamp from the flow log start field and @ptr (file:line) that identifies them.
+func (r Reader) Read(paths ...string) (Result, error) {
+	var result Result
+	for _, path := range paths {
+		files, err := listFiles(path)
+		if err != nil {
+			return Result{}, err
+		}
+		for _, file := range files {
+			records, n, err := r.readFile(file)
+			if err != nil {
+				return Result{}, err
+			}
+			result.Records = append(result.Records, records...)
+			result.Files++
+			result.Bytes += n
+		}
+	}
+	return result, nil
+}
+
+// listFiles returns path if it is a file, or files in the directory and its subdirectories. Hidden files and
+// directories are skipped.
+func listFiles(path string) ([]string, error) {
+	if path == StdinPath {
+		return []string{path}, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != path && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func (r Reader) readFile(path string) ([]map[string]string, int64, error) {
+	if path == StdinPath {
+		return r.parse("stdin", os.Stdin, nil)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	return r.parse(path, f, hivePartitions(path))
+}
+
+// parse parses records of a single file. First line can be header with log format fields, otherwise reader log format
+// is used. Empty lines and comments (#) are skipped.
+func (r Reader) parse(name string, in io.Reader, partitions map[string]string) ([]map[string]string, int64, error) {
+	reader, err := decompress(bufio.NewReader(in))
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", name, err)
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	var records []map[string]string
+	var n int64
+	var lineNumber int
+	var header query.FlowLogFields
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		n += int64(len(scanner.Bytes()) + 1)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if header == nil && len(records) == 0 && query.IsLogFormatHeader(line) {
+			header = query.ParseLogFormat(line)
+			continue
+		}
+
+		record, err := r.parseRecord(header, line)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s:%d: %w", name, lineNumber, err)
+		}
+		for key, field := range hiveFieldByKey {
+			if _, ok := record[field]; !ok && partitions[key] != "" {
+				record[field] = partitions[key]
+			}
+		}
+		record["@ptr"] = fmt.Sprintf("%s:%d", name, lineNumber)
+		record["@timestamp"] = timestamp(record["start"])
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return records, n, nil
+}
+
+// parseRecord parses record with file header, reader log format or log format with the same number of values
+func (r Reader) parseRecord(header query.FlowLogFields, line string) (map[string]string, error) {
+	if header != nil {
+		return header.ParseRecord(line)
+	}
+	if r.logFormat != nil {
+		return r.logFormat.ParseRecord(line)
+	}
+	values := len(strings.Fields(line))
+	for _, fields := range logFormats {
+		if len(fields) == values {
+			return fields.ParseRecord(line)
+		}
+	}
+	return nil, fmt.Errorf("unknown log format of record with %d values, set log format", values)
+}
+
+// decompress returns gzip reader for gzip compressed input, input is checked for gzip magic number, not file extension
+func decompress(in *bufio.Reader) (io.Reader, error) {
+	magic, err := in.Peek(4)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gzip.NewReader(in)
+	case bytes.Equal(magic, []byte("PAR1")):
+		return nil, errors.New("parquet files are not supported, use text file format")
+	}
+	return in, nil
+}
+
+// hivePartitions returns key=value path segments, e.g. aws-region=eu-west-2 from S3 flow log delivery path
+// AWSLogs/aws-account-id=123456789012/aws-service=vpcflowlogs/aws-region=eu-west-2/year=2024/month=05/day=01/...
+func hivePartitions(path string) map[string]string {
+	out := make(map[string]string)
+	for _, segment := range strings.Split(filepath.ToSlash(filepath.Dir(path)), "/") {
+		if key, value, ok := strings.Cut(segment, "="); ok {
+			out[key] = value
+		}
+	}
+	return out
+}
+
+// timestamp returns flow log start (unix seconds) in the same format as the Logs Insights @timestamp field
+func timestamp(start string) string {
+	sec, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return ""
+	}
+	return time.Unix(sec, 0).UTC().Format(query.InsightsTimeLayout)
+}
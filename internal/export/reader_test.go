@@ -0,0 +1,116 @@
+package export
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	defaultRecord = "2 123456789012 eni-123 10.0.0.1 52.1.2.3 45678 443 6 10 840 1733323807 1733323867 ACCEPT OK"
+	v5Record      = "eni-123 10.0.0.1 52.1.2.3 45678 443 6 10 840 1733323807 1733323867 ACCEPT OK vpc-1 subnet-1 i-1 18 IPv4 10.0.0.1 52.1.2.3 - S3 egress 8"
+)
+
+func TestReadDetectsFormat(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "pasted.txt"), "# pasted from ticket\n\n"+defaultRecord+"\n"+v5Record+"\n")
+
+	result, err := NewReader("").Read(dir)
+	if err != nil {
+		t.Fatalf("Read() unexpected error: %v", err)
+	}
+	if result.Files != 1 || len(result.Records) != 2 {
+		t.Fatalf("Read() returned %d files and %d records, want 1 and 2", result.Files, len(result.Records))
+	}
+	if got := result.Records[0]["accountId"]; got != "123456789012" {
+		t.Errorf("default format accountId = %q", got)
+	}
+	if got := result.Records[1]["flowDirection"]; got != "egress" {
+		t.Errorf("cli format flowDirection = %q", got)
+	}
+	if got := result.Records[1]["@timestamp"]; got != "2024-12-04 14:50:07.000" {
+		t.Errorf("@timestamp = %q", got)
+	}
+	if got := result.Records[1]["@ptr"]; got != filepath.Join(dir, "pasted.txt")+":4" {
+		t.Errorf("@ptr = %q", got)
+	}
+}
+
+func TestReadHiveGzipHeader(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "AWSLogs", "aws-account-id=123456789012", "aws-service=vpcflowlogs", "aws-region=eu-west-2",
+		"year=2024", "month=12", "day=04", "hour=14", "flowlogs.log.gz")
+	writeGzipFile(t, path, "version interface-id srcaddr dstaddr srcport dstport protocol action log-status\n"+
+		"5 eni-1 10.0.0.1 10.0.0.2 1234 22 6 REJECT OK\n")
+	writeFile(t, filepath.Join(dir, ".hidden"), "not a flow log\n")
+
+	result, err := NewReader("").Read(dir)
+	if err != nil {
+		t.Fatalf("Read() unexpected error: %v", err)
+	}
+	if result.Files != 1 || len(result.Records) != 1 {
+		t.Fatalf("Read() returned %d files and %d records, want 1 and 1", result.Files, len(result.Records))
+	}
+	record := result.Records[0]
+	want := map[string]string{"dstPort": "22", "action": "REJECT", "region": "eu-west-2", "accountId": "123456789012", "@timestamp": ""}
+	for k, v := range want {
+		if record[k] != v {
+			t.Errorf("field %s: got %q, want %q", k, record[k], v)
+		}
+	}
+}
+
+func TestReadLogFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "custom.log")
+	writeFile(t, path, "eni-1 10.0.0.1 REJECT\n")
+
+	if _, err := NewReader("").Read(path); err == nil || !strings.Contains(err.Error(), "custom.log:1") {
+		t.Errorf("Read() expected error with file and line, got %v", err)
+	}
+	result, err := NewReader("${interface-id} ${srcaddr} ${action}").Read(path)
+	if err != nil {
+		t.Fatalf("Read() unexpected error: %v", err)
+	}
+	if got := result.Records[0]["srcAddr"]; got != "10.0.0.1" {
+		t.Errorf("srcAddr = %q", got)
+	}
+}
+
+func TestReadParquet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flowlogs.parquet")
+	writeFile(t, path, "PAR1...")
+	if _, err := NewReader("").Read(path); err == nil || !strings.Contains(err.Error(), "parquet") {
+		t.Errorf("Read() expected parquet error, got %v", err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeGzipFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w := gzip.NewWriter(f)
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,256 @@
+package ipranges
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// URL is AWS published ip-ranges.json
+const URL = "https://ip-ranges.amazonaws.com/ip-ranges.json"
+
+const (
+	fileName = "ip-ranges.json"
+	// amazonService is listed for every AWS address, specific service (S3, EC2, ...) is listed for the same, more or
+	// less specific prefix
+	amazonService = "AMAZON"
+)
+
+// Range is AWS ip range (prefix) with its region and services, services are sorted
+type Range struct {
+	Prefix   netip.Prefix
+	Region   string
+	Services []string
+}
+
+// Service returns comma separated services of the range, AMAZON is omitted if more specific service is listed
+func (r Range) Service() string {
+	if len(r.Services) > 1 {
+		return strings.Join(slices.DeleteFunc(slices.Clone(r.Services), func(s string) bool { return s == amazonService }), ",")
+	}
+	return strings.Join(r.Services, ",")
+}
+
+// HasService returns true if the range is used by the service (case-insensitive), every range is used by AMAZON
+func (r Range) HasService(service string) bool {
+	return slices.ContainsFunc(r.Services, func(s string) bool { return strings.EqualFold(s, service) })
+}
+
+// Filter selects addresses by services and regions of their ip range, empty filter matches all addresses
+type Filter struct {
+	Services []string
+	Regions  []string
+}
+
+func (f Filter) IsEmpty() bool {
+	return len(f.Services) == 0 && len(f.Regions) == 0
+}
+
+// Ranges is longest prefix match table of AWS ip ranges, zero value has no ranges
+type Ranges struct {
+	CreateDate    string
+	rangeByPrefix map[netip.Prefix]*Range
+	// ipv4Bits and ipv6Bits are prefix lengths present in the table, longest first
+	ipv4Bits []int
+	ipv6Bits []int
+}
+
+// ipRangesFile is ip-ranges.json format, see https://docs.aws.amazon.com/vpc/latest/userguide/aws-ip-ranges.html
+type ipRangesFile struct {
+	CreateDate string `json:"createDate"`
+	Prefixes   []struct {
+		IPPrefix string `json:"ip_prefix"`
+		Region   string `json:"region"`
+		Service  string `json:"service"`
+	} `json:"prefixes"`
+	IPv6Prefixes []struct {
+		IPv6Prefix string `json:"ipv6_prefix"`
+		Region     string `json:"region"`
+		Service    string `json:"service"`
+	} `json:"ipv6_prefixes"`
+}
+
+// Parse parses ip-ranges.json, prefixes listed multiple times (for AMAZON and specific services) are merged
+func Parse(r io.Reader) (Ranges, error) {
+	var in ipRangesFile
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return Ranges{}, fmt.Errorf("decode ip ranges: %w", err)
+	}
+
+	ranges := Ranges{CreateDate: in.CreateDate, rangeByPrefix: make(map[netip.Prefix]*Range)}
+	add := func(prefix, region, service string) error {
+		p, err := netip.ParsePrefix(prefix)
+		if err != nil {
+			return fmt.Errorf("ip ranges: %w", err)
+		}
+		p = p.Masked()
+		r, ok := ranges.rangeByPrefix[p]
+		if !ok {
+			r = &Range{Prefix: p, Region: region}
+			ranges.rangeByPrefix[p] = r
+			bits := &ranges.ipv4Bits
+			if p.Addr().Is6() {
+				bits = &ranges.ipv6Bits
+			}
+			if !slices.Contains(*bits, p.Bits()) {
+				*bits = append(*bits, p.Bits())
+			}
+		}
+		if !slices.Contains(r.Services, service) {
+			r.Services = append(r.Services, service)
+			slices.Sort(r.Services)
+		}
+		return nil
+	}
+	for _, p := range in.Prefixes {
+		if err := add(p.IPPrefix, p.Region, p.Service); err != nil {
+			return Ranges{}, err
+		}
+	}
+	for _, p := range in.IPv6Prefixes {
+		if err := add(p.IPv6Prefix, p.Region, p.Service); err != nil {
+			return Ranges{}, err
+		}
+	}
+	if len(ranges.rangeByPrefix) == 0 {
+		return Ranges{}, errors.New("ip ranges: no prefixes")
+	}
+	slices.Sort(ranges.ipv4Bits)
+	slices.Reverse(ranges.ipv4Bits)
+	slices.Sort(ranges.ipv6Bits)
+	slices.Reverse(ranges.ipv6Bits)
+	return ranges, nil
+}
+
+// Len returns number of prefixes
+func (r Ranges) Len() int {
+	return len(r.rangeByPrefix)
+}
+
+// Lookup returns the most specific AWS ip range of the address, false is returned if the address is not AWS address
+func (r Ranges) Lookup(addr string) (Range, bool) {
+	ranges := r.containing(addr)
+	if len(ranges) == 0 {
+		return Range{}, false
+	}
+	return *ranges[0], true
+}
+
+// Match returns true if the address is AWS address of any of the filter services and regions (case-insensitive).
+// All ranges containing the address are checked, not only the most specific one, because service can be listed for
+// wider prefix than AMAZON (e.g. AMAZON only /24 in S3 /16).
+func (r Ranges) Match(addr string, filter Filter) bool {
+	if filter.IsEmpty() {
+		return true
+	}
+	return slices.ContainsFunc(r.containing(addr), func(rng *Range) bool {
+		if len(filter.Services) > 0 && !slices.ContainsFunc(filter.Services, rng.HasService) {
+			return false
+		}
+		if len(filter.Regions) > 0 && !slices.ContainsFunc(filter.Regions, func(region string) bool {
+			return strings.EqualFold(region, rng.Region)
+		}) {
+			return false
+		}
+		return true
+	})
+}
+
+// containing returns ip ranges that contain the address, the most specific first
+func (r Ranges) containing(addr string) []*Range {
+	a, err := netip.ParseAddr(addr)
+	if err != nil || len(r.rangeByPrefix) == 0 {
+		return nil
+	}
+	a = a.Unmap()
+	bits := r.ipv4Bits
+	if a.Is6() {
+		bits = r.ipv6Bits
+	}
+	var out []*Range
+	for _, b := range bits {
+		p, err := a.Prefix(b)
+		if err != nil {
+			continue
+		}
+		if rng, ok := r.rangeByPrefix[p]; ok {
+			out = append(out, rng)
+		}
+	}
+	return out
+}
+
+// CachePath returns path of the cached ip-ranges.json in the user cache directory (e.g. ~/.cache/flowlogs)
+func CachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("user cache dir: %w", err)
+	}
+	return filepath.Join(dir, "flowlogs", fileName), nil
+}
+
+// Load loads ip ranges from the file
+func Load(path string) (Ranges, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Ranges{}, fmt.Errorf("%s not found, run update-ranges command or set ip-ranges flag", path)
+		}
+		return Ranges{}, fmt.Errorf("read ip ranges: %w", err)
+	}
+	defer f.Close()
+
+	ranges, err := Parse(f)
+	if err != nil {
+		return Ranges{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return ranges, nil
+}
+
+// Update downloads ip-ranges.json from the url and saves it to the path, the file is replaced only if the downloaded
+// ip ranges are valid
+func Update(ctx context.Context, url, path string) (Ranges, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Ranges{}, fmt.Errorf("download ip ranges: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Ranges{}, fmt.Errorf("download ip ranges: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Ranges{}, fmt.Errorf("download ip ranges: %s", resp.Status)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Ranges{}, fmt.Errorf("download ip ranges: %w", err)
+	}
+
+	ranges, err := Parse(bytes.NewReader(b))
+	if err != nil {
+		return Ranges{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return Ranges{}, fmt.Errorf("create ip ranges dir: %w", err)
+	}
+	// write to temporary file and rename it, so readers never see partially written file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return Ranges{}, fmt.Errorf("write ip ranges: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return Ranges{}, fmt.Errorf("write ip ranges: %w", err)
+	}
+	return ranges, nil
+}
@@ -0,0 +1,147 @@
+package ipranges
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const ipRangesJSON = `{
+  "syncToken": "1733323807",
+  "createDate": "2024-12-04-14-50-07",
+  "prefixes": [
+    {"ip_prefix": "3.0.0.0/15", "region": "us-east-1", "service": "AMAZON", "network_border_group": "us-east-1"},
+    {"ip_prefix": "3.5.0.0/19", "region": "us-east-1", "service": "AMAZON", "network_border_group": "us-east-1"},
+    {"ip_prefix": "3.5.0.0/19", "region": "us-east-1", "service": "S3", "network_border_group": "us-east-1"},
+    {"ip_prefix": "52.94.0.0/22", "region": "eu-west-2", "service": "AMAZON", "network_border_group": "eu-west-2"},
+    {"ip_prefix": "52.94.0.0/22", "region": "eu-west-2", "service": "DYNAMODB", "network_border_group": "eu-west-2"},
+    {"ip_prefix": "52.94.0.0/22", "region": "eu-west-2", "service": "EC2", "network_border_group": "eu-west-2"},
+    {"ip_prefix": "54.231.0.0/16", "region": "us-west-2", "service": "AMAZON", "network_border_group": "us-west-2"},
+    {"ip_prefix": "54.231.0.0/16", "region": "us-west-2", "service": "S3", "network_border_group": "us-west-2"},
+    {"ip_prefix": "54.231.1.0/24", "region": "us-west-2", "service": "AMAZON", "network_border_group": "us-west-2"}
+  ],
+  "ipv6_prefixes": [
+    {"ipv6_prefix": "2600:1f18::/33", "region": "us-east-1", "service": "EC2", "network_border_group": "us-east-1"}
+  ]
+}`
+
+func TestLookup(t *testing.T) {
+	ranges, err := Parse(strings.NewReader(ipRangesJSON))
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	if ranges.Len() != 6 {
+		t.Errorf("Len() = %d, want 6", ranges.Len())
+	}
+
+	tests := []struct {
+		addr    string
+		ok      bool
+		prefix  string
+		service string
+		region  string
+	}{
+		{addr: "3.5.1.2", ok: true, prefix: "3.5.0.0/19", service: "S3", region: "us-east-1"},
+		{addr: "3.1.1.2", ok: true, prefix: "3.0.0.0/15", service: "AMAZON", region: "us-east-1"},
+		{addr: "52.94.3.4", ok: true, prefix: "52.94.0.0/22", service: "DYNAMODB,EC2", region: "eu-west-2"},
+		{addr: "::ffff:3.5.1.2", ok: true, prefix: "3.5.0.0/19", service: "S3", region: "us-east-1"},
+		{addr: "2600:1f18:1::1", ok: true, prefix: "2600:1f18::/33", service: "EC2", region: "us-east-1"},
+		{addr: "54.231.1.5", ok: true, prefix: "54.231.1.0/24", service: "AMAZON", region: "us-west-2"},
+		{addr: "10.0.0.1"},
+		{addr: "-"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.addr, func(t *testing.T) {
+			r, ok := ranges.Lookup(tc.addr)
+			if ok != tc.ok {
+				t.Fatalf("Lookup() ok = %t, want %t", ok, tc.ok)
+			}
+			if !ok {
+				return
+			}
+			if r.Prefix.String() != tc.prefix || r.Service() != tc.service || r.Region != tc.region {
+				t.Errorf("Lookup() = %s %s %s, want %s %s %s", r.Prefix, r.Service(), r.Region, tc.prefix, tc.service, tc.region)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	ranges, err := Parse(strings.NewReader(ipRangesJSON))
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		addr   string
+		filter Filter
+		want   bool
+	}{
+		{name: "empty filter", addr: "10.0.0.1", filter: Filter{}, want: true},
+		{name: "service", addr: "3.5.1.2", filter: Filter{Services: []string{"s3"}}, want: true},
+		{name: "amazon matches specific service", addr: "3.5.1.2", filter: Filter{Services: []string{"AMAZON"}}, want: true},
+		{name: "other service", addr: "3.1.1.2", filter: Filter{Services: []string{"S3"}}, want: false},
+		{name: "any service", addr: "52.94.3.4", filter: Filter{Services: []string{"S3", "EC2"}}, want: true},
+		{name: "region", addr: "52.94.3.4", filter: Filter{Regions: []string{"EU-WEST-2"}}, want: true},
+		{name: "service and other region", addr: "3.5.1.2", filter: Filter{Services: []string{"S3"}, Regions: []string{"eu-west-2"}}, want: false},
+		{name: "service of wider prefix", addr: "54.231.1.5", filter: Filter{Services: []string{"S3"}}, want: true},
+		{name: "service and region of wider prefix", addr: "54.231.1.5", filter: Filter{Services: []string{"S3"}, Regions: []string{"us-west-2"}}, want: true},
+		{name: "not aws address", addr: "10.0.0.1", filter: Filter{Regions: []string{"us-east-1"}}, want: false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ranges.Match(tc.addr, tc.filter); got != tc.want {
+				t.Errorf("Match(%s) = %t, want %t", tc.addr, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, in := range []string{`not json`, `{"prefixes": []}`, `{"prefixes": [{"ip_prefix": "3.5.0.0"}]}`} {
+		if _, err := Parse(strings.NewReader(in)); err == nil {
+			t.Errorf("Parse(%s) expected error", in)
+		}
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	body := ipRangesJSON
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "flowlogs", fileName)
+	ranges, err := Update(context.Background(), server.URL, path)
+	if err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+	if ranges.CreateDate != "2024-12-04-14-50-07" {
+		t.Errorf("CreateDate = %q", ranges.CreateDate)
+	}
+	if _, err := Load(path); err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+
+	// invalid ip ranges do not replace the cached file
+	body = `{"prefixes": []}`
+	if _, err := Update(context.Background(), server.URL, path); err == nil {
+		t.Fatal("Update() expected error")
+	}
+	if b, _ := os.ReadFile(path); string(b) != ipRangesJSON {
+		t.Error("Update() replaced cached file with invalid ip ranges")
+	}
+}
+
+func TestLoadNotFound(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), fileName))
+	if err == nil || !strings.Contains(err.Error(), "update-ranges") {
+		t.Errorf("Load() error = %v, want hint to run update-ranges", err)
+	}
+}
@@ -1,15 +1,27 @@
 package main
 
 import (
-	"github.com/pete911/flowlogs/cmd"
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pete911/flowlogs/cmd"
 )
 
 var Version = "dev"
 
 func main() {
 	cmd.Version = Version
-	if err := cmd.Root.Execute(); err != nil {
+
+	// first interrupt cancels running queries, second one kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if err := cmd.Root.ExecuteContext(ctx); err != nil {
 		os.Exit(1)
 	}
 }